@@ -34,9 +34,10 @@ func main() {
 
 	// server initialization
 	logger := pkglog.GetLogger().With("pkg", "main")
+	handler, drain := router.New(v, *debugF)
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", *portF),
-		Handler:      router.New(v, *debugF),
+		Handler:      handler,
 		WriteTimeout: 30 * time.Second,
 		ReadTimeout:  30 * time.Second,
 	}
@@ -62,6 +63,9 @@ func main() {
 		if err := srv.Shutdown(shutdownCtx); err != nil {
 			logger.Error("graceful shutdown failed; exiting forcefully", "err", err)
 		}
+		if err := drain(shutdownCtx); err != nil {
+			logger.Error("background work did not finish before shutdown timeout", "err", err)
+		}
 		serverCancel()
 	}()
 
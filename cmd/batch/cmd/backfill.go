@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sb-scanner/model"
+	"sb-scanner/pkg/config"
+	pkglog "sb-scanner/pkg/logger"
+	"sb-scanner/pkg/repository"
+)
+
+func Backfill() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Sync a long time range in chunks.",
+		Long: "Sync commits of a long time range chunk by chunk, each with a sync run of its own. " +
+			"Completed chunks are recorded, so that a rerun over the same range skips them and resumes the one it stopped at. " +
+			"Backfill doesn't advance watermarks of keyword groups.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfgF, err := cmd.Flags().GetString("config")
+			if err != nil {
+				slog.Error("failed to read config flag", "err", err)
+				os.Exit(1)
+			}
+			v, err := config.ReadConfig(cfgF)
+			if err != nil {
+				slog.Error("failed to read config", "err", err)
+				os.Exit(1)
+			}
+			pkglog.InitLogger(v.GetString("loglevel"))
+			logger := pkglog.GetLogger().With("cmd", "backfill")
+
+			fromF, _ := cmd.Flags().GetString("from")
+			from, err := parseTimeFlag(fromF)
+			if err != nil {
+				logger.Error("invalid --from", "err", err)
+				os.Exit(1)
+			}
+			chunkF, _ := cmd.Flags().GetString("chunk")
+			chunk, err := parseChunkFlag(chunkF)
+			if err != nil {
+				logger.Error("invalid --chunk", "err", err)
+				os.Exit(1)
+			}
+			toF, _ := cmd.Flags().GetString("to")
+			var to time.Time
+			if toF != "" {
+				if to, err = parseTimeFlag(toF); err != nil {
+					logger.Error("invalid --to", "err", err)
+					os.Exit(1)
+				}
+			} else {
+				// the last whole chunk, so that chunks and their IDs are the same when the command is run again
+				to = from.Add(time.Since(from) / chunk * chunk)
+			}
+			if !from.Before(to) {
+				logger.Error("--from must be before --to, and at least a chunk ago if --to is not set")
+				os.Exit(1)
+			}
+			orderF, _ := cmd.Flags().GetString("order")
+			if orderF != "newest" && orderF != "oldest" {
+				logger.Error("--order must be newest or oldest")
+				os.Exit(1)
+			}
+
+			repo, err := repository.NewRepository(v.GetString("db.url"), v.GetString("db.name"))
+			if err != nil {
+				logger.Error("failed to initialize repository", "err", err)
+				os.Exit(1)
+			}
+			if err := repo.EnsureIndexes(context.Background()); err != nil {
+				logger.Error("failed to ensure db indexes", "err", err)
+				os.Exit(1)
+			}
+			h, err := newSyncHandler(v, logger, repo)
+			if err != nil {
+				logger.Error("failed to initialize sync", "err", err)
+				os.Exit(1)
+			}
+
+			ctx, stop, cancel := signalContexts()
+			defer cancel()
+			ctx, release, err := newSyncLease(logger, repo, h.leaseTTL).acquire(ctx)
+			if err != nil {
+				logger.Error("failed to acquire sync lease", "err", err)
+				os.Exit(1)
+			}
+			b := &backfillHandler{
+				logger: logger,
+				h:      h,
+				repo:   repo,
+			}
+			err = b.Run(ctx, stop, splitRange(from, to, chunk, orderF == "newest"))
+			// released before exiting, so that others don't have to wait for it to expire
+			release()
+			if err != nil {
+				logger.Error("backfill failed; rerun the same command to resume", "err", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.String("from", "", "start of the range, as date (2006-01-02) or in RFC3339 format")
+	flags.String("to", "", "end of the range, as date (2006-01-02) or in RFC3339 format (default: end of the last whole chunk until now)")
+	flags.String("chunk", "7d", "length of chunks, in days (7d), weeks (2w) or as Go duration (12h)")
+	flags.String("order", "newest", "order to sync chunks in, newest or oldest first")
+	cmd.MarkFlagRequired("from")
+	return cmd
+}
+
+// parseTimeFlag parses a time given as date in UTC or in RFC3339 format.
+func parseTimeFlag(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseChunkFlag parses a duration, which can also be given in days or weeks.
+func parseChunkFlag(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("invalid chunk length %q", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Minute {
+		return 0, fmt.Errorf("invalid chunk length %q, must be at least a minute", s)
+	}
+	return d, nil
+}
+
+// backfillChunk is the window of a chunk.
+type backfillChunk struct {
+	since, until time.Time
+}
+
+// splitRange splits the range into chunks aligned to from, so that the chunks are the same in either order.
+// The last chunk is shorter if the range isn't a multiple of the chunk length.
+func splitRange(from, to time.Time, chunk time.Duration, newestFirst bool) []backfillChunk {
+	var chunks []backfillChunk
+	for since := from; since.Before(to); since = since.Add(chunk) {
+		until := since.Add(chunk)
+		if until.After(to) {
+			until = to
+		}
+		chunks = append(chunks, backfillChunk{since: since, until: until})
+	}
+	if newestFirst {
+		slices.Reverse(chunks)
+	}
+	return chunks
+}
+
+type backfillHandler struct {
+	logger *slog.Logger
+	h      *syncHandler
+	repo   *repository.Repository
+}
+
+// Run syncs chunks in order, skipping completed ones and resuming the sync run of a chunk that didn't complete.
+// Once stop is done, the chunk being synced is stopped gracefully and no more chunks are synced.
+func (b *backfillHandler) Run(ctx, stop context.Context, chunks []backfillChunk) error {
+	ids := make([]string, 0, len(chunks))
+	for _, c := range chunks {
+		ids = append(ids, model.NewBackfillChunkID(c.since, c.until))
+	}
+	recorded, err := b.repo.GetBackfillChunks(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	progress := &progressBar{out: os.Stderr, total: len(chunks)}
+	for _, id := range ids {
+		if recorded[id].Status == model.SyncRunCompleted {
+			progress.done++
+		}
+	}
+	b.logger.Info("starting backfill", "chunks", len(chunks), "completed", progress.done)
+	progress.print()
+
+	for i, c := range chunks {
+		if stop.Err() != nil {
+			b.logger.Info("backfill stopped; rerun the same command to resume")
+			return nil
+		}
+		rec, ok := recorded[ids[i]]
+		if ok && rec.Status == model.SyncRunCompleted {
+			continue
+		}
+
+		var run *model.SyncRun
+		if ok && rec.RunID != "" {
+			// the run may have been completed by serve, which resumes the latest incomplete run
+			if prev, err := b.repo.GetSyncRun(ctx, rec.RunID); err == nil && prev.Status == model.SyncRunCompleted {
+				rec.Status = model.SyncRunCompleted
+				if err := b.repo.PutBackfillChunk(ctx, rec); err != nil {
+					return err
+				}
+				progress.done++
+				progress.print()
+				continue
+			}
+			if run, err = b.h.resumeRun(ctx, rec.RunID); err != nil {
+				b.logger.Warn("failed to resume sync run of chunk, starting a new one", "run_id", rec.RunID, "err", err)
+				run = nil
+			}
+		}
+		if run == nil {
+			if run, err = b.h.newRun(ctx, c.since, c.until, nil, true); err != nil {
+				return err
+			}
+		}
+		chunk := model.BackfillChunk{ID: ids[i], Since: c.since, Until: c.until, RunID: run.ID, Status: model.SyncRunRunning}
+		if err := b.repo.PutBackfillChunk(ctx, chunk); err != nil {
+			return err
+		}
+		b.logger.Info("backfilling chunk", "since", c.since, "until", c.until, "run_id", run.ID)
+
+		start := time.Now()
+		runErr := b.h.Run(ctx, stop, run)
+		// the run records whether it completed or was stopped
+		finished, err := b.repo.GetSyncRun(context.WithoutCancel(ctx), run.ID)
+		if err != nil {
+			return err
+		}
+		chunk.Status = finished.Status
+		if err := b.repo.PutBackfillChunk(context.WithoutCancel(ctx), chunk); err != nil {
+			return err
+		}
+		if runErr != nil {
+			return runErr
+		}
+		if chunk.Status == model.SyncRunCompleted {
+			progress.add(time.Since(start))
+			progress.print()
+		}
+	}
+	b.logger.Info("backfill completed", "chunks", len(chunks))
+	return nil
+}
+
+// progressBar prints progress of chunks with an ETA estimated from chunks synced so far, skipped ones aside.
+type progressBar struct {
+	out   io.Writer
+	total int
+	done  int
+
+	synced  int // chunks synced by this process
+	elapsed time.Duration
+}
+
+func (p *progressBar) add(elapsed time.Duration) {
+	p.done++
+	p.synced++
+	p.elapsed += elapsed
+}
+
+func (p *progressBar) print() {
+	const width = 40
+	filled := width * p.done / max(p.total, 1)
+	eta := "unknown"
+	if p.synced > 0 {
+		eta = (p.elapsed / time.Duration(p.synced) * time.Duration(p.total-p.done)).Round(time.Second).String()
+	}
+	fmt.Fprintf(p.out, "backfill [%s%s] %d/%d chunks (%.1f%%), ETA %s\n",
+		strings.Repeat("#", filled), strings.Repeat(".", width-filled),
+		p.done, p.total, 100*float64(p.done)/float64(max(p.total, 1)), eta)
+}
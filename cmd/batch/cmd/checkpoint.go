@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"sb-scanner/model"
+	"sb-scanner/pkg/repository"
+)
+
+// checkpointSaveThreshold is the number of evaluated commits after which they are saved even if the checkpoint
+// hasn't moved, so that a long page being evaluated isn't evaluated again after resuming.
+const checkpointSaveThreshold = 50
+
+// trackedPage is a page whose commits are in the pipeline. It is done once every commit is either dropped,
+// evaluated not to contain profanity, or written to database.
+type trackedPage struct {
+	pos     model.SyncCheckpoint
+	pending int
+}
+
+// checkpointTracker moves the checkpoint of a sync run over pages in the order they were searched, as they get done.
+// Pages are done out of order since commits are evaluated concurrently.
+// Watermarks of keyword groups are advanced as the checkpoint moves past them, except for backfill runs: an old
+// window would otherwise set the watermark of a group without one, and the next sync would start from there.
+type checkpointTracker struct {
+	logger *slog.Logger
+	repo   *repository.Repository
+	ctx    context.Context
+	run    *model.SyncRun
+
+	mu        sync.Mutex
+	pages     []*trackedPage // in search order, from the first page that is not done
+	pos       model.SyncCheckpoint
+	evaluated []string // keys of commits evaluated since the last save
+}
+
+// newCheckpointTracker creates a tracker saving checkpoints of the run. Checkpoints are still saved after ctx is
+// cancelled, so that progress made before is kept.
+func newCheckpointTracker(ctx context.Context, logger *slog.Logger, repo *repository.Repository, run *model.SyncRun) *checkpointTracker {
+	return &checkpointTracker{
+		logger: logger,
+		repo:   repo,
+		ctx:    context.WithoutCancel(ctx),
+		run:    run,
+		pos:    run.Checkpoint,
+	}
+}
+
+// add starts tracking a page, which is held until released with done by the caller.
+func (t *checkpointTracker) add(pos model.SyncCheckpoint) *trackedPage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := &trackedPage{pos: pos, pending: 1}
+	t.pages = append(t.pages, p)
+	return p
+}
+
+// hold adds n commits of the page, each to be released with done.
+func (t *checkpointTracker) hold(p *trackedPage, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p.pending += n
+}
+
+// done releases a commit or the caller's hold of the page. key is set for evaluated commits.
+func (t *checkpointTracker) done(p *trackedPage, key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p.pending--
+	if key != "" {
+		t.evaluated = append(t.evaluated, key)
+	}
+
+	prev := t.pos
+	for len(t.pages) > 0 && t.pages[0].pending == 0 {
+		t.pos = t.pages[0].pos
+		t.pages = t.pages[1:]
+	}
+	if t.pos != prev || len(t.evaluated) >= checkpointSaveThreshold {
+		// saved under the lock, so that checkpoints are never saved out of order
+		if err := t.repo.SaveSyncCheckpoint(t.ctx, t.run.ID, t.pos, t.evaluated); err != nil {
+			t.logger.Warn("failed to save sync checkpoint", "run_id", t.run.ID, "err", err)
+		} else {
+			t.evaluated = nil
+		}
+	}
+	for g := prev.Group; g < t.pos.Group && !t.run.Backfill; g++ {
+		t.advanceWatermark(t.run.Groups[g])
+	}
+}
+
+func (t *checkpointTracker) advanceWatermark(group string) {
+	since := t.run.SinceFor(group)
+	moved, err := t.repo.AdvanceSyncWatermark(t.ctx, group, since, t.run.Until)
+	if err != nil {
+		t.logger.Warn("failed to advance sync watermark", "group", group, "err", err)
+		return
+	}
+	if moved {
+		t.logger.Info("advanced sync watermark", "group", group, "watermark", t.run.Until)
+	} else {
+		t.logger.Info("sync watermark is not advanced, window doesn't extend it", "group", group, "since", since)
+	}
+}
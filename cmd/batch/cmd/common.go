@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/viper"
+
+	"sb-scanner/model"
+	"sb-scanner/pkg/sentiment"
+	"sb-scanner/pkg/sentiment/ollama"
+	"sb-scanner/pkg/source"
+)
+
+// newEvaluator creates sentiment evaluator from `ollama` config block.
+func newEvaluator(v *viper.Viper, logger *slog.Logger) (sentiment.Evaluator, error) {
+	ollamaModel := v.GetString("ollama.model")
+	if ollamaModel == "" {
+		return nil, fmt.Errorf("ollama.model is not set")
+	}
+	ollamaURL := v.GetString("ollama.url")
+	if ollamaURL == "" {
+		logger.Warn("ollama.url is not set; using default(http://localhost:11434)")
+		ollamaURL = "http://localhost:11434"
+	}
+	return ollama.NewOllamaEvaluator(ollamaModel, ollamaURL), nil
+}
+
+// newCommit builds commit document from a commit found by the source and its evaluated sentiment.
+func newCommit(sourceName string, c source.Commit, s sentiment.Sentiment) model.Commit {
+	commit := model.Commit{
+		ID:      model.NewCommitID(c.Time, c.SHA),
+		Source:  sourceName,
+		SHA:     c.SHA,
+		URL:     c.URL,
+		Message: c.Message,
+		Author:  c.Author,
+		Time:    c.Time,
+		Sentiment: model.Sentiment{
+			Score: s.Score,
+			Model: s.Model,
+		},
+		Repository:  c.Repository,
+		Fingerprint: model.NewCommitFingerprint(c.AuthorEmail, c.Time, c.Message),
+	}
+	if c.Repository != nil {
+		commit.Repositories = []string{c.Repository.FullName}
+	}
+	return commit
+}
+
+// signalContexts returns stop, which is done on the first interrupt or SIGTERM to stop gracefully, and its parent
+// ctx, which is cancelled on the second one to stop right away.
+func signalContexts() (ctx, stop context.Context, cancel func()) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	stop, cancelStop := context.WithCancel(ctx)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-signals:
+			case <-ctx.Done():
+				return
+			}
+			if i == 0 {
+				slog.Warn("stopping gracefully, send the signal again to stop right away")
+				cancelStop()
+				continue
+			}
+			cancelCtx()
+			return
+		}
+	}()
+	return ctx, stop, func() {
+		signal.Stop(signals)
+		cancelCtx()
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"sb-scanner/pkg/repository"
+)
+
+// syncLeaseName is the lease held by the process that is syncing, whether by sync, backfill or serve.
+const syncLeaseName = "sync"
+
+// errSyncLeaseHeld is returned when another process is syncing.
+var errSyncLeaseHeld = errors.New("sync lease is held by another process")
+
+// leaseHolder identifies this process as holder of leases.
+func leaseHolder() string {
+	hostname, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), hex.EncodeToString(b))
+}
+
+// syncLease keeps sync runs of different processes from overlapping, so that no two of them advance the same
+// checkpoint or watermarks.
+type syncLease struct {
+	logger *slog.Logger
+	repo   *repository.Repository
+	holder string
+	ttl    time.Duration
+}
+
+func newSyncLease(logger *slog.Logger, repo *repository.Repository, ttl time.Duration) *syncLease {
+	return &syncLease{
+		logger: logger,
+		repo:   repo,
+		holder: leaseHolder(),
+		ttl:    ttl,
+	}
+}
+
+// acquire takes the lease, or returns errSyncLeaseHeld. The lease is renewed until release is called. If it is lost,
+// e.g. because the database was unreachable for longer than the lease TTL, the returned ctx is cancelled, so that
+// the run stops before another process takes over.
+func (l *syncLease) acquire(ctx context.Context) (context.Context, func(), error) {
+	ok, err := l.repo.AcquireLease(ctx, syncLeaseName, l.holder, l.ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, errSyncLeaseHeld
+	}
+
+	leaseCtx, cancel := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		l.renew(leaseCtx, cancel)
+	}()
+	release := func() {
+		cancel()
+		<-renewed
+		if err := l.repo.ReleaseLease(context.WithoutCancel(ctx), syncLeaseName, l.holder); err != nil {
+			l.logger.Warn("failed to release sync lease", "err", err)
+		}
+	}
+	return leaseCtx, release, nil
+}
+
+func (l *syncLease) renew(ctx context.Context, lost func()) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := l.repo.AcquireLease(ctx, syncLeaseName, l.holder, l.ttl)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil && time.Since(renewed) < l.ttl:
+			l.logger.Warn("failed to renew sync lease, retrying", "err", err)
+		case err != nil || !ok:
+			l.logger.Error("lost sync lease, cancelling sync run", "err", err)
+			lost()
+			return
+		default:
+			renewed = time.Now()
+		}
+	}
+}
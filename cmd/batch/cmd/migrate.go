@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"sb-scanner/pkg/config"
+	pkglog "sb-scanner/pkg/logger"
+	"sb-scanner/pkg/repository"
+)
+
+func Migrate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate stored commits.",
+		Long: "Merge commits stored more than once and rewrite commits stored with an older ID format. " +
+			"Sync and API server should be stopped while migrating.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfgF, err := cmd.Flags().GetString("config")
+			if err != nil {
+				slog.Error("failed to read config flag", "err", err)
+				os.Exit(1)
+			}
+			v, err := config.ReadConfig(cfgF)
+			if err != nil {
+				slog.Error("failed to read config", "err", err)
+				os.Exit(1)
+			}
+			pkglog.InitLogger(v.GetString("loglevel"))
+			logger := pkglog.GetLogger().With("cmd", "migrate")
+
+			repo, err := repository.NewRepository(v.GetString("db.url"), v.GetString("db.name"))
+			if err != nil {
+				logger.Error("failed to initialize repository", "err", err)
+				os.Exit(1)
+			}
+			ctx := context.Background()
+			// commits stored more than once would otherwise get the same new ID
+			if err := repo.MergeDuplicateCommits(ctx); err != nil {
+				logger.Error("failed to merge duplicate commits", "err", err)
+				os.Exit(1)
+			}
+			if err := repo.EnsureIndexes(ctx); err != nil {
+				logger.Error("failed to ensure db indexes", "err", err)
+				os.Exit(1)
+			}
+			migrated, err := repo.MigrateCommitIDs(ctx)
+			if err != nil {
+				logger.Error("failed to migrate commit ids", "err", err)
+				os.Exit(1)
+			}
+			// indexes are not carried over to the rewritten collection
+			if err := repo.EnsureIndexes(ctx); err != nil {
+				logger.Error("failed to ensure db indexes", "err", err)
+				os.Exit(1)
+			}
+			logger.Info("migrated commit ids", "commits_migrated", migrated)
+		},
+	}
+	return cmd
+}
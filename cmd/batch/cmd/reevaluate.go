@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"sb-scanner/model"
+	"sb-scanner/pkg/config"
+	pkglog "sb-scanner/pkg/logger"
+	"sb-scanner/pkg/repository"
+	"sb-scanner/pkg/sentiment"
+)
+
+func Reevaluate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reevaluate",
+		Short: "Score stored commits again.",
+		Long: "Evaluate sentiment of stored commits again with the given model, e.g. after the prompt was tuned. " +
+			"Previous scores are kept as sentiment history of the commits. Commits are rescored from the newest; " +
+			"an interrupted run selecting commits by --where-model continues where it stopped when run again.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfgF, err := cmd.Flags().GetString("config")
+			if err != nil {
+				slog.Error("failed to read config flag", "err", err)
+				os.Exit(1)
+			}
+			v, err := config.ReadConfig(cfgF)
+			if err != nil {
+				slog.Error("failed to read config", "err", err)
+				os.Exit(1)
+			}
+			pkglog.InitLogger(v.GetString("loglevel"))
+			logger := pkglog.GetLogger().With("cmd", "reevaluate")
+
+			var q repository.CommitQuery
+			q.Model, _ = cmd.Flags().GetString("where-model")
+			if sinceF, _ := cmd.Flags().GetString("since"); sinceF != "" {
+				if q.Since, err = parseTimeFlag(sinceF); err != nil {
+					logger.Error("invalid --since", "err", err)
+					os.Exit(1)
+				}
+			}
+			modelF, _ := cmd.Flags().GetString("model")
+			v.Set("ollama.model", modelF)
+			evaluator, err := newEvaluator(v, logger)
+			if err != nil {
+				logger.Error("failed to initialize evaluator", "err", err)
+				os.Exit(1)
+			}
+
+			evaluatorConcurrency := v.GetInt("sync.evaluator_concurrency")
+			if evaluatorConcurrency <= 0 {
+				logger.Warn("invalid sync.evaluator_concurrency, using default (4)")
+				evaluatorConcurrency = 4
+			}
+			writeBatchSize := v.GetInt("sync.write_batch_size")
+			if writeBatchSize <= 0 {
+				logger.Warn("invalid sync.write_batch_size, using default (50)")
+				writeBatchSize = 50
+			}
+
+			repo, err := repository.NewRepository(v.GetString("db.url"), v.GetString("db.name"))
+			if err != nil {
+				logger.Error("failed to initialize repository", "err", err)
+				os.Exit(1)
+			}
+			if err := repo.EnsureIndexes(context.Background()); err != nil {
+				logger.Error("failed to ensure db indexes", "err", err)
+				os.Exit(1)
+			}
+
+			ctx, stop, cancel := signalContexts()
+			defer cancel()
+			h := &reevaluateHandler{
+				logger:               logger,
+				repo:                 repo,
+				evaluator:            evaluator,
+				evaluatorConcurrency: evaluatorConcurrency,
+				writeBatchSize:       writeBatchSize,
+			}
+			if err := h.Run(ctx, stop, q); err != nil {
+				logger.Error("reevaluate failed", "err", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.String("model", "", "name of the Ollama model to score commits with")
+	flags.String("where-model", "", "only rescore commits scored with this model")
+	flags.String("since", "", "only rescore commits made since, as date (2006-01-02) or in RFC3339 format")
+	cmd.MarkFlagRequired("model")
+	return cmd
+}
+
+type reevaluateHandler struct {
+	logger               *slog.Logger
+	repo                 *repository.Repository
+	evaluator            sentiment.Evaluator
+	evaluatorConcurrency int
+	writeBatchSize       int
+
+	read, rescored, unflagged atomic.Int64
+}
+
+// rescoredCommit is a commit with its new sentiment.
+type rescoredCommit struct {
+	id        string
+	sentiment model.Sentiment
+}
+
+// Run rescores commits selected by the query, evaluating them concurrently and writing new scores in batches.
+// Once stop is done no more commits are read, and the ones being evaluated are still written.
+func (h *reevaluateHandler) Run(ctx, stop context.Context, q repository.CommitQuery) error {
+	start := time.Now()
+	g, ctx := errgroup.WithContext(ctx)
+	readCtx, cancelRead := context.WithCancel(ctx)
+	defer cancelRead()
+	unregister := context.AfterFunc(stop, cancelRead)
+	defer unregister()
+	commits := make(chan model.Commit, 2*h.evaluatorConcurrency)
+	rescored := make(chan rescoredCommit, h.writeBatchSize)
+
+	g.Go(func() error {
+		defer close(commits)
+		for c, err := range h.repo.StreamCommits(readCtx, q, int64(h.writeBatchSize)) {
+			if err != nil {
+				if readCtx.Err() != nil {
+					return ctx.Err()
+				}
+				return err
+			}
+			select {
+			case commits <- c:
+				h.read.Add(1)
+			case <-readCtx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	var workers sync.WaitGroup
+	for range h.evaluatorConcurrency {
+		workers.Add(1)
+		g.Go(func() error {
+			defer workers.Done()
+			for c := range commits {
+				s, err := h.evaluator.Evaluate(ctx, c.Message)
+				if err != nil {
+					h.logger.Error("failed to evaluate sentiment", "err", err, "commit_sha", c.SHA)
+					return err
+				}
+				h.logger.Debug("rescored commit", "commit_sha", c.SHA,
+					"previous_score", c.Sentiment.Score, "previous_model", c.Sentiment.Model,
+					"sentiment_score", s.Score, "model", s.Model)
+				if !s.ContainsProfanity {
+					// kept, since scores of different models are to be compared
+					h.unflagged.Add(1)
+					h.logger.Info("commit does not contain profanity according to the model", "commit_sha", c.SHA, "model", s.Model)
+				}
+				select {
+				case rescored <- rescoredCommit{id: c.ID, sentiment: model.Sentiment{Score: s.Score, Model: s.Model}}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	}
+	go func() {
+		workers.Wait()
+		close(rescored)
+	}()
+
+	g.Go(func() error {
+		// written even after cancellation, so that evaluated scores aren't lost
+		writeCtx := context.WithoutCancel(ctx)
+		batch := make(map[string]model.Sentiment, h.writeBatchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := h.repo.UpdateCommitSentiments(writeCtx, batch); err != nil {
+				return err
+			}
+			h.rescored.Add(int64(len(batch)))
+			h.logger.Info("wrote commit sentiments", "commits", len(batch), "rescored", h.rescored.Load(), "elapsed", time.Since(start).Round(time.Second))
+			clear(batch)
+			return nil
+		}
+		for c := range rescored {
+			batch[c.id] = c.sentiment
+			if len(batch) >= h.writeBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return flush()
+	})
+
+	err := g.Wait()
+	h.logger.Info("reevaluate finished",
+		"read", h.read.Load(),
+		"rescored", h.rescored.Load(),
+		"unflagged", h.unflagged.Load(),
+		"stopped", stop.Err() != nil,
+		"elapsed", time.Since(start).Round(time.Millisecond))
+	return err
+}
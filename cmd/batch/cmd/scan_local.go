@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sb-scanner/model"
+	"sb-scanner/pkg/config"
+	pkglog "sb-scanner/pkg/logger"
+	"sb-scanner/pkg/repository"
+	"sb-scanner/pkg/sentiment"
+	"sb-scanner/pkg/source"
+	"sb-scanner/pkg/source/local"
+)
+
+func ScanLocal() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scan-local",
+		Short: "Scan a local git repository.",
+		Long:  "Scan commit history of a local git repository and print a report or save flagged commits to database.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfgF, err := cmd.Flags().GetString("config")
+			if err != nil {
+				slog.Error("failed to read config flag", "err", err)
+				os.Exit(1)
+			}
+			v, err := config.ReadConfig(cfgF)
+			if err != nil {
+				slog.Error("failed to read config", "err", err)
+				os.Exit(1)
+			}
+			pkglog.InitLogger(v.GetString("loglevel"))
+			logger := pkglog.GetLogger().With("cmd", "scan-local")
+
+			pathF, _ := cmd.Flags().GetString("path")
+			if pathF == "" {
+				logger.Error("--path is required")
+				os.Exit(1)
+			}
+			nameF, _ := cmd.Flags().GetString("name")
+
+			keywordGroups, err := config.ReadKeywordGroups(v)
+			if err != nil {
+				logger.Error("failed to read keyword groups", "err", err)
+				os.Exit(1)
+			}
+			maxCommitLength := v.GetInt("github.search.max_commit_length")
+			if maxCommitLength <= 0 {
+				logger.Warn("invalid github.search.max_commit_length, using default (500)")
+				maxCommitLength = 500
+			}
+			evaluator, err := newEvaluator(v, logger)
+			if err != nil {
+				logger.Error("failed to initialize sentiment evaluator", "err", err)
+				os.Exit(1)
+			}
+
+			var repo *repository.Repository
+			if store, _ := cmd.Flags().GetBool("store"); store {
+				repo, err = repository.NewRepository(v.GetString("db.url"), v.GetString("db.name"))
+				if err != nil {
+					logger.Error("failed to initialize repository", "err", err)
+					os.Exit(1)
+				}
+				if err := repo.EnsureIndexes(context.Background()); err != nil {
+					logger.Error("failed to ensure db indexes", "err", err)
+					os.Exit(1)
+				}
+			}
+
+			// unlike sync, local scan defaults to the whole history
+			var stime, etime time.Time
+			if stimeF, _ := cmd.Flags().GetString("stime"); stimeF != "" {
+				if stime, err = time.Parse(time.RFC3339, stimeF); err != nil {
+					logger.Error("invalid stime format", "err", err)
+					os.Exit(1)
+				}
+			}
+			if etimeF, _ := cmd.Flags().GetString("etime"); etimeF != "" {
+				if etime, err = time.Parse(time.RFC3339, etimeF); err != nil {
+					logger.Error("invalid etime format", "err", err)
+					os.Exit(1)
+				}
+			}
+
+			h := &scanLocalHandler{
+				logger:          logger,
+				source:          local.NewSource(pathF, nameF),
+				evaluator:       evaluator,
+				repo:            repo,
+				out:             cmd.OutOrStdout(),
+				maxCommitLength: maxCommitLength,
+				keywordGroups:   keywordGroups,
+			}
+			if err := h.Run(stime, etime); err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.String("path", "", "path to local git repository")
+	flags.String("name", "", "repository name to record on commits (default: base name of path)")
+	flags.String("stime", "", "scan start time in RFC3339 format (default: beginning of history)")
+	flags.String("etime", "", "scan end time in RFC3339 format (default: now)")
+	flags.Bool("store", false, "save flagged commits to database instead of printing a report")
+	cmd.PersistentFlags().AddFlagSet(flags)
+	return cmd
+}
+
+type scanLocalHandler struct {
+	logger    *slog.Logger
+	source    source.CommitSource
+	evaluator sentiment.Evaluator
+	repo      *repository.Repository // nil if only printing report
+	out       io.Writer
+
+	maxCommitLength int
+	keywordGroups   []source.KeywordGroup
+}
+
+func (h *scanLocalHandler) Run(stime, etime time.Time) error {
+	shaMap := make(map[string]bool)
+	var matched int
+	var flagged []model.Commit
+	for _, group := range h.keywordGroups {
+		q := source.Query{
+			Group: group,
+			Since: stime,
+			Until: etime,
+		}
+		if err := h.source.Search(context.Background(), q, func(page source.Page) error {
+			return h.processPage(page, shaMap, &matched, &flagged)
+		}); err != nil {
+			h.logger.Error("failed to scan local repository", "err", err, "group", group.Name)
+			return err
+		}
+	}
+
+	if h.repo != nil {
+		h.logger.Info("scanned local repository", "commits_matched", matched, "commits_inserted", len(flagged))
+		return nil
+	}
+	h.printReport(matched, flagged)
+	return nil
+}
+
+func (h *scanLocalHandler) processPage(page source.Page, shaMap map[string]bool, matched *int, flagged *[]model.Commit) error {
+	var commits []model.Commit
+	for _, c := range page.Commits {
+		// commits matching more than one group are evaluated once
+		if shaMap[c.SHA] {
+			continue
+		}
+		shaMap[c.SHA] = true
+		*matched++
+		if len(c.Message) > h.maxCommitLength {
+			h.logger.Info("skipping commit with message exceeding max length", "commit_sha", c.SHA, "message_length", len(c.Message))
+			continue
+		}
+		sentiment, err := h.evaluator.Evaluate(context.Background(), c.Message)
+		if err != nil {
+			h.logger.Error("failed to evaluate sentiment", "err", err, "commit_sha", c.SHA)
+			return err
+		}
+		if !sentiment.ContainsProfanity {
+			continue
+		}
+		commits = append(commits, newCommit(h.source.Name(), c, sentiment))
+	}
+	if h.repo != nil && len(commits) > 0 {
+		if err := h.repo.PutCommits(context.Background(), commits); err != nil {
+			h.logger.Error("failed to put commits to db", "err", err)
+			return err
+		}
+	}
+	*flagged = append(*flagged, commits...)
+	return nil
+}
+
+func (h *scanLocalHandler) printReport(matched int, flagged []model.Commit) {
+	tw := tabwriter.NewWriter(h.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SHA\tTIME\tAUTHOR\tSCORE\tMESSAGE")
+	for _, c := range flagged {
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%s\n", c.SHA[:7], c.Time.Format(time.DateOnly), c.Author.Username, c.Sentiment.Score, subject)
+	}
+	tw.Flush()
+	fmt.Fprintf(h.out, "\n%d commits matched keywords, %d flagged\n", matched, len(flagged))
+}
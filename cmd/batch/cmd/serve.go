@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/spf13/cobra"
+
+	"sb-scanner/model"
+	"sb-scanner/pkg/config"
+	pkglog "sb-scanner/pkg/logger"
+	"sb-scanner/pkg/repository"
+	"sb-scanner/pkg/schedule"
+)
+
+func Serve() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run sync on a schedule.",
+		Long: "Run sync on a cron schedule until stopped. Only one replica syncs at a time. " +
+			"Health and status of the last run are served over HTTP.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfgF, err := cmd.Flags().GetString("config")
+			if err != nil {
+				slog.Error("failed to read config flag", "err", err)
+				os.Exit(1)
+			}
+			v, err := config.ReadConfig(cfgF)
+			if err != nil {
+				slog.Error("failed to read config", "err", err)
+				os.Exit(1)
+			}
+			pkglog.InitLogger(v.GetString("loglevel"))
+			logger := pkglog.GetLogger().With("cmd", "serve")
+
+			repo, err := repository.NewRepository(v.GetString("db.url"), v.GetString("db.name"))
+			if err != nil {
+				logger.Error("failed to initialize repository", "err", err)
+				os.Exit(1)
+			}
+			if err := repo.EnsureIndexes(context.Background()); err != nil {
+				logger.Error("failed to ensure db indexes", "err", err)
+				os.Exit(1)
+			}
+			h, err := newSyncHandler(v, logger, repo)
+			if err != nil {
+				logger.Error("failed to initialize sync", "err", err)
+				os.Exit(1)
+			}
+
+			scheduleExpr := v.GetString("serve.schedule")
+			if scheduleExpr == "" {
+				logger.Warn("serve.schedule is not set, using default (@hourly)")
+				scheduleExpr = "@hourly"
+			}
+			sched, err := schedule.Parse(scheduleExpr, time.Local)
+			if err != nil {
+				logger.Error("invalid serve.schedule", "err", err)
+				os.Exit(1)
+			}
+			maxResumeAttempts := v.GetInt("serve.max_resume_attempts")
+			if maxResumeAttempts <= 0 {
+				logger.Warn("invalid serve.max_resume_attempts, using default (3)")
+				maxResumeAttempts = 3
+			}
+			addr := v.GetString("serve.addr")
+			if addr == "" {
+				logger.Warn("serve.addr is not set, using default (:8081)")
+				addr = ":8081"
+			}
+
+			s := &scheduler{
+				logger:            logger,
+				h:                 h,
+				repo:              repo,
+				schedule:          sched,
+				lease:             newSyncLease(logger, repo, h.leaseTTL),
+				maxResumeAttempts: maxResumeAttempts,
+			}
+			srv := &http.Server{Addr: addr, Handler: s.handler()}
+			go func() {
+				logger.Info("serving status", "addr", addr)
+				if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					logger.Error("failed to serve status", "err", err)
+					os.Exit(1)
+				}
+			}()
+
+			ctx, stop, cancel := signalContexts()
+			defer cancel()
+			s.Run(ctx, stop)
+
+			shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancelShutdown()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				logger.Error("failed to shut down status server", "err", err)
+			}
+		},
+	}
+	return cmd
+}
+
+// scheduler runs sync on a schedule, while holding the sync lease.
+type scheduler struct {
+	logger            *slog.Logger
+	h                 *syncHandler
+	repo              *repository.Repository
+	schedule          schedule.Schedule
+	lease             *syncLease
+	maxResumeAttempts int
+
+	mu      sync.Mutex
+	running bool
+	next    time.Time
+}
+
+// Run runs sync at every activation of the schedule until stop is done. A run in progress is stopped gracefully,
+// or right away once ctx is cancelled.
+func (s *scheduler) Run(ctx, stop context.Context) {
+	for {
+		next := s.schedule.Next(time.Now())
+		if next.IsZero() {
+			s.logger.Error("schedule has no next activation, stopping")
+			return
+		}
+		s.mu.Lock()
+		s.next = next
+		s.mu.Unlock()
+		s.logger.Info("next sync scheduled", "at", next)
+
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-t.C:
+		case <-stop.Done():
+			t.Stop()
+			return
+		}
+		s.runOnce(ctx, stop)
+	}
+}
+
+// runOnce runs sync if no other process is syncing.
+func (s *scheduler) runOnce(ctx, stop context.Context) {
+	runCtx, release, err := s.lease.acquire(ctx)
+	if errors.Is(err, errSyncLeaseHeld) {
+		s.logger.Info("sync lease is held by another process, skipping")
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to acquire sync lease", "err", err)
+		return
+	}
+	defer release()
+
+	record, err := s.nextRun(runCtx)
+	if err != nil {
+		s.logger.Error("failed to start sync run", "err", err)
+		return
+	}
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+	// errors are logged and recorded to the run
+	_ = s.h.Run(runCtx, stop, record)
+}
+
+// nextRun resumes the latest sync run if it hasn't completed, e.g. because the process running it was killed.
+// Otherwise, or once the run has been resumed maxResumeAttempts times, a new run continues keyword groups from
+// their watermarks up to now.
+func (s *scheduler) nextRun(ctx context.Context) (*model.SyncRun, error) {
+	latest, err := s.repo.GetLatestSyncRun(ctx)
+	switch {
+	case errors.Is(err, repository.ErrSyncRunNotFound):
+	case err != nil:
+		return nil, err
+	case latest.Status == model.SyncRunCompleted:
+	case latest.Status == model.SyncRunRunning && time.Since(latest.UpdatedAt) < s.h.leaseTTL:
+		// runs are updated while they run, so a recently updated one may still be run by a process that lost the
+		// lease without noticing yet
+		return nil, fmt.Errorf("latest sync run %s was updated %s ago and may still be running", latest.ID, time.Since(latest.UpdatedAt).Round(time.Second))
+	case latest.Attempts >= s.maxResumeAttempts:
+		s.logger.Warn("giving up on latest sync run, starting a new one", "run_id", latest.ID, "attempts", latest.Attempts, "err", latest.Error)
+		errMsg := fmt.Sprintf("gave up after %d resume attempts", latest.Attempts)
+		if latest.Error != "" {
+			errMsg += ": " + latest.Error
+		}
+		if err := s.repo.SetSyncRunStatus(ctx, latest.ID, model.SyncRunFailed, errMsg); err != nil {
+			return nil, err
+		}
+	default:
+		run, err := s.h.resumeRun(ctx, latest.ID)
+		if err == nil {
+			return run, nil
+		}
+		s.logger.Warn("failed to resume latest sync run, starting a new one", "run_id", latest.ID, "err", err)
+	}
+
+	etime := time.Now()
+	stime := etime.Add(-24 * time.Hour)
+	groupSince, err := s.h.watermarkWindows(ctx, stime, etime)
+	if err != nil {
+		return nil, err
+	}
+	return s.h.newRun(ctx, stime, etime, groupSince, false)
+}
+
+// schedulerStatus is the response of the status endpoint.
+type schedulerStatus struct {
+	Running bool           `json:"running"` // whether this replica is syncing
+	NextRun time.Time      `json:"next_run"`
+	LastRun *model.SyncRun `json:"last_run,omitempty"` // latest run of any replica
+}
+
+func (s *scheduler) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		status := schedulerStatus{Running: s.running, NextRun: s.next}
+		s.mu.Unlock()
+
+		latest, err := s.repo.GetLatestSyncRun(r.Context())
+		switch {
+		case errors.Is(err, repository.ErrSyncRunNotFound):
+		case err != nil:
+			s.logger.Error("failed to get latest sync run", "err", err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, map[string]string{"status": "error"})
+			return
+		default:
+			status.LastRun = &latest
+		}
+		render.JSON(w, r, status)
+	})
+	return mux
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"sb-scanner/pkg/github"
+	"sb-scanner/pkg/repository"
+	"sb-scanner/pkg/source"
+	"sb-scanner/pkg/source/gitea"
+	githubsource "sb-scanner/pkg/source/github"
+	"sb-scanner/pkg/source/gitlab"
+)
+
+// newGitHubClient creates GitHub API client from `github` config block.
+func newGitHubClient(v *viper.Viper, logger *slog.Logger, repo *repository.Repository) (github.Client, error) {
+	rateLimitMaxRetries := 3
+	if v.IsSet("github.rate_limit.max_retries") {
+		rateLimitMaxRetries = v.GetInt("github.rate_limit.max_retries")
+		if rateLimitMaxRetries < 0 {
+			logger.Warn("invalid github.rate_limit.max_retries, using default (3)")
+			rateLimitMaxRetries = 3
+		}
+	}
+	rateLimitMaxWait := 5 * time.Minute
+	if v.IsSet("github.rate_limit.max_wait") {
+		rateLimitMaxWait = v.GetDuration("github.rate_limit.max_wait")
+		if rateLimitMaxWait <= 0 {
+			logger.Warn("invalid github.rate_limit.max_wait, using default (5m)")
+			rateLimitMaxWait = 5 * time.Minute
+		}
+	}
+	githubOpts := []github.ClientOption{
+		github.WithHTTPClient(&http.Client{Timeout: 30 * time.Second}),
+		github.WithRateLimitMaxRetries(rateLimitMaxRetries),
+		github.WithRateLimitMaxWait(rateLimitMaxWait),
+	}
+
+	if baseURL := v.GetString("github.base_url"); baseURL != "" {
+		githubOpts = append(githubOpts, github.WithBaseURL(baseURL))
+	}
+	switch cacheType := v.GetString("github.cache.type"); cacheType {
+	case "":
+	case "memory":
+		maxEntries := v.GetInt("github.cache.max_entries")
+		if maxEntries <= 0 {
+			logger.Warn("invalid github.cache.max_entries, using default (10000)")
+			maxEntries = 10000
+		}
+		ttl := v.GetDuration("github.cache.ttl")
+		if ttl <= 0 {
+			logger.Warn("invalid github.cache.ttl, using default (24h)")
+			ttl = 24 * time.Hour
+		}
+		githubOpts = append(githubOpts, github.WithCache(github.NewMemoryCache(maxEntries, ttl)))
+	case "disk":
+		dir := v.GetString("github.cache.dir")
+		if dir == "" {
+			return nil, fmt.Errorf("github.cache.dir is not set")
+		}
+		cache, err := github.NewDiskCache(dir)
+		if err != nil {
+			return nil, err
+		}
+		githubOpts = append(githubOpts, github.WithCache(cache))
+	case "mongo":
+		githubOpts = append(githubOpts, github.WithCache(repo.HTTPCache()))
+	default:
+		return nil, fmt.Errorf("unknown github.cache.type %q", cacheType)
+	}
+
+	// pool members fail fast when rate limited, so that the pool moves on to a member with quota left; the pool
+	// itself waits and retries once every member is exhausted
+	pooled := githubPoolEnabled(v)
+	memberOpts := append(slices.Clone(githubOpts), github.WithRateLimitMaxRetries(0), github.WithRateLimitMaxWait(time.Second))
+
+	var members []github.PoolMember
+	if v.GetBool("github.auth.enabled") {
+		pk, err := github.LoadPrivateKey(v.GetString("github.auth.key_file"), []byte(v.GetString("github.auth.key")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid github.auth.key or github.auth.key_file: %w", err)
+		}
+		installationID := v.GetString("github.auth.installation_id")
+		opts := githubOpts
+		if pooled {
+			opts = memberOpts
+		}
+		cli, err := github.NewAuthenticatedClient(v.GetString("github.auth.app_id"), installationID, pk, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if !pooled {
+			return cli, nil
+		}
+		members = append(members, github.PoolMember{Name: "installation:" + installationID, Client: cli.DefaultClient})
+	}
+	if !pooled {
+		logger.Warn("github authentication is disabled, using unauthenticated client with lower rate limits")
+		return github.NewDefaultClient(githubOpts...), nil
+	}
+
+	var installations []struct {
+		AppID          string `mapstructure:"app_id"`
+		InstallationID string `mapstructure:"installation_id"`
+		Key            string `mapstructure:"key"`
+		KeyFile        string `mapstructure:"key_file"`
+	}
+	if err := v.UnmarshalKey("github.pool.installations", &installations); err != nil {
+		return nil, fmt.Errorf("invalid github.pool.installations: %w", err)
+	}
+	for i, inst := range installations {
+		pk, err := github.LoadPrivateKey(inst.KeyFile, []byte(inst.Key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key or key_file of github.pool.installations[%d]: %w", i, err)
+		}
+		cli, err := github.NewAuthenticatedClient(inst.AppID, inst.InstallationID, pk, memberOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("github.pool.installations[%d]: %w", i, err)
+		}
+		members = append(members, github.PoolMember{Name: "installation:" + inst.InstallationID, Client: cli.DefaultClient})
+	}
+	for i, token := range v.GetStringSlice("github.pool.tokens") {
+		if token == "" {
+			return nil, fmt.Errorf("github.pool.tokens[%d] is empty", i)
+		}
+		members = append(members, github.PoolMember{Name: fmt.Sprintf("token:%d", i), Client: github.NewTokenClient(token, memberOpts...)})
+	}
+	logger.Info("using pooled github client", "members", len(members))
+	return github.NewPooledClient(rateLimitMaxRetries, rateLimitMaxWait, members...)
+}
+
+// githubPoolEnabled reports whether additional credentials are configured for the GitHub client pool.
+func githubPoolEnabled(v *viper.Viper) bool {
+	installations, _ := v.Get("github.pool.installations").([]any)
+	return len(installations) > 0 || len(v.GetStringSlice("github.pool.tokens")) > 0
+}
+
+// githubAuthenticated reports whether every GitHub request is sent with credentials.
+func githubAuthenticated(v *viper.Viper) bool {
+	return v.GetBool("github.auth.enabled") || githubPoolEnabled(v)
+}
+
+// newSources creates commit sources enabled in config. GitHub is always enabled.
+func newSources(v *viper.Viper, logger *slog.Logger, repo *repository.Repository) ([]source.CommitSource, error) {
+	githubCli, err := newGitHubClient(v, logger, repo)
+	if err != nil {
+		return nil, err
+	}
+	perPage := v.GetInt("github.search.per_page")
+	if perPage <= 0 || perPage > 100 {
+		logger.Warn("invalid github.search.per_page, using default (30)")
+		perPage = 30
+	}
+	maxPage := v.GetInt("github.search.max_pages")
+	if maxPage <= 0 {
+		logger.Warn("invalid github.search.max_pages, using default (10)")
+		maxPage = 10
+	}
+	enrichMode := githubsource.EnrichNone
+	if v.GetBool("github.enrich.enabled") {
+		switch mode := githubsource.EnrichMode(v.GetString("github.enrich.mode")); mode {
+		case githubsource.EnrichREST, githubsource.EnrichGraphQL:
+			enrichMode = mode
+		default:
+			// GraphQL API is not available without authentication
+			enrichMode = githubsource.EnrichREST
+			if githubAuthenticated(v) {
+				enrichMode = githubsource.EnrichGraphQL
+			}
+			if mode != githubsource.EnrichNone {
+				logger.Warn("invalid github.enrich.mode, using default", "mode", enrichMode)
+			}
+		}
+		if enrichMode == githubsource.EnrichGraphQL && !githubAuthenticated(v) {
+			return nil, fmt.Errorf("github.enrich.mode graphql requires github.auth.enabled or github.pool")
+		}
+	}
+	sources := []source.CommitSource{
+		githubsource.NewSource(githubCli, perPage, maxPage, enrichMode),
+	}
+
+	if v.GetBool("sources.gitlab.enabled") {
+		baseURL := v.GetString("sources.gitlab.base_url")
+		if baseURL == "" {
+			logger.Warn("sources.gitlab.base_url is not set; using default(https://gitlab.com)")
+			baseURL = "https://gitlab.com"
+		}
+		perPage := v.GetInt("sources.gitlab.per_page")
+		if perPage <= 0 || perPage > 100 {
+			logger.Warn("invalid sources.gitlab.per_page, using default (20)")
+			perPage = 20
+		}
+		maxPage := v.GetInt("sources.gitlab.max_pages")
+		if maxPage <= 0 {
+			logger.Warn("invalid sources.gitlab.max_pages, using default (10)")
+			maxPage = 10
+		}
+		gitlabCli := gitlab.NewClient(baseURL, v.GetString("sources.gitlab.token"), &http.Client{Timeout: 30 * time.Second})
+		sources = append(sources, gitlab.NewSource(gitlabCli, v.GetStringSlice("sources.gitlab.projects"), perPage, maxPage,
+			v.GetBool("sources.gitlab.allow_private")))
+	}
+
+	if v.GetBool("sources.gitea.enabled") {
+		baseURL := v.GetString("sources.gitea.base_url")
+		if baseURL == "" {
+			return nil, fmt.Errorf("sources.gitea.base_url is not set")
+		}
+		perPage := v.GetInt("sources.gitea.per_page")
+		if perPage <= 0 || perPage > 50 {
+			logger.Warn("invalid sources.gitea.per_page, using default (50)")
+			perPage = 50
+		}
+		maxPage := v.GetInt("sources.gitea.max_pages")
+		if maxPage <= 0 {
+			logger.Warn("invalid sources.gitea.max_pages, using default (10)")
+			maxPage = 10
+		}
+		giteaCli := gitea.NewClient(baseURL, v.GetString("sources.gitea.token"), &http.Client{Timeout: 30 * time.Second})
+		sources = append(sources, gitea.NewSource(giteaCli, v.GetStringSlice("sources.gitea.owners"), perPage, maxPage,
+			v.GetBool("sources.gitea.allow_private")))
+	}
+
+	return sources, nil
+}
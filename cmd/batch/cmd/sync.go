@@ -5,24 +5,28 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/sync/errgroup"
 
 	"sb-scanner/model"
 	"sb-scanner/pkg/config"
-	"sb-scanner/pkg/github"
 	pkglog "sb-scanner/pkg/logger"
 	"sb-scanner/pkg/repository"
 	"sb-scanner/pkg/sentiment"
-	"sb-scanner/pkg/sentiment/ollama"
+	"sb-scanner/pkg/source"
 )
 
 func Sync() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "sync",
 		Short: "Search and save commits.",
-		Long:  "Search commits from GitHub and save to database.",
+		Long:  "Search commits from GitHub and other configured sources and save to database.",
 		Run: func(cmd *cobra.Command, args []string) {
 			cfgF, err := cmd.Flags().GetString("config")
 			if err != nil {
@@ -37,91 +41,81 @@ func Sync() *cobra.Command {
 			pkglog.InitLogger(v.GetString("loglevel"))
 			logger := pkglog.GetLogger().With("cmd", "sync")
 
-			var githubCli github.Client
-			if v.GetBool("github.auth.enabled") {
-				githubCli, err = github.NewAuthenticatedClient(v.GetString("github.auth.app_id"), v.GetString("github.auth.installation_id"), []byte(v.GetString("github.auth.key")))
-				if err != nil {
-					logger.Error("failed to initialize github client", "err", err)
-					os.Exit(1)
-				}
-			} else {
-				logger.Warn("github authentication is disabled, using unauthenticated client with lower rate limits")
-				githubCli = github.NewDefaultClient()
-			}
 			repo, err := repository.NewRepository(v.GetString("db.url"), v.GetString("db.name"))
 			if err != nil {
 				logger.Error("failed to initialize repository", "err", err)
 				os.Exit(1)
 			}
-
-			searchKeywords := v.GetStringSlice("github.search.keywords")
-			if len(searchKeywords) == 0 {
-				logger.Error("no search keywords provided in configuration")
+			if err := repo.EnsureIndexes(context.Background()); err != nil {
+				logger.Error("failed to ensure db indexes", "err", err)
 				os.Exit(1)
 			}
-			perPage := v.GetInt("github.search.per_page")
-			if perPage <= 0 || perPage > 100 {
-				logger.Warn("invalid github.search.per_page, using default (30)")
-				perPage = 30
-			}
-			maxPage := v.GetInt("github.search.max_pages")
-			if maxPage <= 0 {
-				logger.Warn("invalid github.search.max_pages, using default (10)")
-				maxPage = 10
-			}
-			rateLimitWait := v.GetDuration("github.search.rate_limit_wait")
-			if rateLimitWait < 0 {
-				logger.Warn("invalid github.search.rate_limit_wait, using default (2s)")
-				rateLimitWait = 2 * time.Second
-			}
-			maxCommitLength := v.GetInt("github.search.max_commit_length")
-			if maxCommitLength <= 0 {
-				logger.Warn("invalid github.search.max_commit_length, using default (500)")
-				maxCommitLength = 500
-			}
-
-			ollamaModel := v.GetString("ollama.model")
-			if ollamaModel == "" {
-				logger.Error("ollama.model is not set")
+			h, err := newSyncHandler(v, logger, repo)
+			if err != nil {
+				logger.Error("failed to initialize sync", "err", err)
 				os.Exit(1)
 			}
-			ollamaURL := v.GetString("ollama.url")
-			if ollamaURL == "" {
-				logger.Warn("ollama.url is not set; using default(http://localhost:11434)")
-				ollamaURL = "http://localhost:11434"
+			ctx, stop, cancel := signalContexts()
+			defer cancel()
+			ctx, release, err := newSyncLease(logger, repo, h.leaseTTL).acquire(ctx)
+			if err != nil {
+				logger.Error("failed to acquire sync lease", "err", err)
+				os.Exit(1)
 			}
-			evaluator := ollama.NewOllamaEvaluator(ollamaModel, ollamaURL)
 
-			now := time.Now()
-			stimeF, _ := cmd.Flags().GetString("stime")
-			stime, err := time.Parse(time.RFC3339, stimeF)
+			start := func() (*model.SyncRun, error) {
+				if resumeF, _ := cmd.Flags().GetString("resume"); resumeF != "" {
+					run, err := h.resumeRun(ctx, resumeF)
+					if err != nil {
+						return nil, fmt.Errorf("failed to resume sync run %s: %w", resumeF, err)
+					}
+					return run, nil
+				}
+				now := time.Now()
+				stimeF, _ := cmd.Flags().GetString("stime")
+				stime, err := time.Parse(time.RFC3339, stimeF)
+				if err != nil {
+					logger.Warn("invalid stime format, using default value", "err", err)
+					stime = now.Add(-24 * time.Hour)
+				}
+				etimeF, _ := cmd.Flags().GetString("etime")
+				etime, err := time.Parse(time.RFC3339, etimeF)
+				if err != nil {
+					logger.Warn("invalid stime format, using default value", "err", err)
+					etime = now
+				}
+				if etime.Before(stime) {
+					logger.Warn("etime is before stime, adjusting etime to now")
+					etime = now
+				}
+				var groupSince map[string]time.Time
+				fromWatermark, _ := cmd.Flags().GetBool("from-watermark")
+				if reset, _ := cmd.Flags().GetBool("reset-watermark"); reset {
+					if err := h.resetWatermarks(ctx); err != nil {
+						return nil, fmt.Errorf("failed to reset sync watermarks: %w", err)
+					}
+				} else if fromWatermark && !cmd.Flags().Changed("stime") {
+					groupSince, err = h.watermarkWindows(ctx, stime, etime)
+					if err != nil {
+						return nil, fmt.Errorf("failed to read sync watermarks: %w", err)
+					}
+				}
+				run, err := h.newRun(ctx, stime, etime, groupSince, false)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create sync run: %w", err)
+				}
+				return run, nil
+			}
+			run, err := start()
 			if err != nil {
-				logger.Warn("invalid stime format, using default value", "err", err)
-				stime = now.Add(-24 * time.Hour)
+				logger.Error("failed to start sync run", "err", err)
+			} else {
+				// errors are logged and recorded to the run
+				err = h.Run(ctx, stop, run)
 			}
-			etimeF, _ := cmd.Flags().GetString("etime")
-			etime, err := time.Parse(time.RFC3339, etimeF)
+			// released before exiting, so that others don't have to wait for it to expire
+			release()
 			if err != nil {
-				logger.Warn("invalid stime format, using default value", "err", err)
-				etime = now
-			}
-			if etime.Before(stime) {
-				logger.Warn("etime is before stime, adjusting etime to now")
-				etime = now
-			}
-
-			h := &syncHandler{
-				logger:          logger,
-				githubCli:       githubCli,
-				evaluator:       evaluator,
-				repo:            repo,
-				searchPerPage:   perPage,
-				searchMaxPage:   maxPage,
-				rateLimitWait:   rateLimitWait,
-				maxCommitLength: maxCommitLength,
-				searchKeywords:  searchKeywords,
-			}
-			if err := h.Run(stime, etime); err != nil {
 				os.Exit(1)
 			}
 		},
@@ -130,107 +124,737 @@ func Sync() *cobra.Command {
 	flags := cmd.Flags()
 	flags.String("stime", "", "search start time in RFC3339 foramt (default: 24 hours ago)")
 	flags.String("etime", "", "search end time in RFC3339 format (default: now)")
+	flags.Bool("from-watermark", true, "start each keyword group from its watermark, the end of its last completed sync, if stime is not set")
+	flags.Bool("reset-watermark", false, "delete watermarks of keyword groups before syncing, so that they start from stime")
+	flags.String("resume", "", "ID of a failed or killed sync run to resume; its search window is used instead of stime and etime")
 	cmd.PersistentFlags().AddFlagSet(flags)
 	return cmd
 }
 
+// newSyncHandler creates sync handler from `github`, `sources`, `sync` and `ollama` config blocks.
+func newSyncHandler(v *viper.Viper, logger *slog.Logger, repo *repository.Repository) (*syncHandler, error) {
+	sources, err := newSources(v, logger, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize commit sources: %w", err)
+	}
+
+	keywordGroups, err := config.ReadKeywordGroups(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyword groups: %w", err)
+	}
+	maxCommitLength := v.GetInt("github.search.max_commit_length")
+	if maxCommitLength <= 0 {
+		logger.Warn("invalid github.search.max_commit_length, using default (500)")
+		maxCommitLength = 500
+	}
+	filter, err := config.ReadFilter(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit filter: %w", err)
+	}
+
+	evaluator, err := newEvaluator(v, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize sentiment evaluator: %w", err)
+	}
+
+	evaluatorConcurrency := v.GetInt("sync.evaluator_concurrency")
+	if evaluatorConcurrency <= 0 {
+		logger.Warn("invalid sync.evaluator_concurrency, using default (4)")
+		evaluatorConcurrency = 4
+	}
+	writeBatchSize := v.GetInt("sync.write_batch_size")
+	if writeBatchSize <= 0 {
+		logger.Warn("invalid sync.write_batch_size, using default (50)")
+		writeBatchSize = 50
+	}
+
+	watermarkOverlap := time.Hour
+	if v.IsSet("sync.watermark_overlap") {
+		watermarkOverlap = v.GetDuration("sync.watermark_overlap")
+		if watermarkOverlap < 0 {
+			logger.Warn("invalid sync.watermark_overlap, using default (1h)")
+			watermarkOverlap = time.Hour
+		}
+	}
+
+	leaseTTL := v.GetDuration("sync.lease_ttl")
+	if leaseTTL <= 0 {
+		logger.Warn("invalid sync.lease_ttl, using default (5m)")
+		leaseTTL = 5 * time.Minute
+	}
+
+	return &syncHandler{
+		logger:               logger,
+		sources:              sources,
+		evaluator:            evaluator,
+		repo:                 repo,
+		maxCommitLength:      maxCommitLength,
+		keywordGroups:        keywordGroups,
+		filter:               filter,
+		evaluatorConcurrency: evaluatorConcurrency,
+		writeBatchSize:       writeBatchSize,
+		watermarkOverlap:     watermarkOverlap,
+		leaseTTL:             leaseTTL,
+	}, nil
+}
+
 type syncHandler struct {
 	logger    *slog.Logger
-	githubCli github.Client
+	sources   []source.CommitSource
 	evaluator sentiment.Evaluator
 	repo      *repository.Repository
 
-	searchPerPage   int
-	searchMaxPage   int
-	rateLimitWait   time.Duration
-	maxCommitLength int
-	searchKeywords  []string
+	maxCommitLength      int
+	keywordGroups        []source.KeywordGroup
+	filter               *source.Filter
+	evaluatorConcurrency int
+	writeBatchSize       int
+	watermarkOverlap     time.Duration
+	leaseTTL             time.Duration // of the sync lease; runs not updated for this long are considered dead
 }
 
-func (h *syncHandler) Run(stime, etime time.Time) error {
-	shaMap := make(map[string]bool)
+// newRun records a new sync run searching commits in the window. groupSince overrides stime for keyword groups.
+// Runs of a backfill don't advance watermarks.
+func (h *syncHandler) newRun(ctx context.Context, stime, etime time.Time, groupSince map[string]time.Time, backfill bool) (*model.SyncRun, error) {
+	run := &model.SyncRun{
+		Since:      stime,
+		Until:      etime,
+		GroupSince: groupSince,
+		Backfill:   backfill,
+	}
+	for _, g := range h.keywordGroups {
+		run.Groups = append(run.Groups, g.Name)
+	}
+	for _, src := range h.sources {
+		run.Sources = append(run.Sources, src.Name())
+	}
+	if err := h.repo.CreateSyncRun(ctx, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
 
-	// max 5 keywords per search due to GitHub Search API limitations
-	for i := 0; i < len(h.searchKeywords); i += 5 {
-		searchPage := 1
-		for searchPage <= h.searchMaxPage {
-			opts := []github.SearchOption{
-				github.WithStartTime(stime),
-				github.WithEndTime(etime),
-				github.WithSize(h.searchPerPage),
-				github.WithPage(searchPage),
+// resumeRun loads a sync run that hasn't completed. Keyword groups and sources must be configured as they were
+// when the run started, since the checkpoint refers to them by position.
+func (h *syncHandler) resumeRun(ctx context.Context, id string) (*model.SyncRun, error) {
+	run, err := h.repo.GetSyncRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == model.SyncRunCompleted {
+		return nil, fmt.Errorf("sync run %s is already completed", id)
+	}
+	var groups, sources []string
+	for _, g := range h.keywordGroups {
+		groups = append(groups, g.Name)
+	}
+	for _, src := range h.sources {
+		sources = append(sources, src.Name())
+	}
+	if !slices.Equal(groups, run.Groups) || !slices.Equal(sources, run.Sources) {
+		return nil, fmt.Errorf("keyword groups %v and sources %v differ from the ones of the run (%v, %v)", groups, sources, run.Groups, run.Sources)
+	}
+	if err := h.repo.ResumeSyncRun(ctx, id); err != nil {
+		return nil, err
+	}
+	run.Status = model.SyncRunRunning
+	run.Attempts++
+	h.logger.Info("resuming sync run", "run_id", id, "checkpoint", run.Checkpoint, "evaluated", len(run.Evaluated), "attempt", run.Attempts)
+	return &run, nil
+}
+
+// resetWatermarks deletes watermarks of the keyword groups, so that they start from stime.
+func (h *syncHandler) resetWatermarks(ctx context.Context) error {
+	var groups []string
+	for _, g := range h.keywordGroups {
+		groups = append(groups, g.Name)
+	}
+	if err := h.repo.ResetSyncWatermarks(ctx, groups); err != nil {
+		return err
+	}
+	h.logger.Info("reset sync watermarks", "groups", groups)
+	return nil
+}
+
+// watermarkWindows returns start of the search window of keyword groups that continue from their watermarks,
+// which is the watermark minus `sync.watermark_overlap` for commits that GitHub indexed late.
+// Groups without watermark start from stime.
+func (h *syncHandler) watermarkWindows(ctx context.Context, stime, etime time.Time) (map[string]time.Time, error) {
+	watermarks, err := h.repo.GetSyncWatermarks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groupSince := make(map[string]time.Time)
+	for _, g := range h.keywordGroups {
+		group := g.Name
+		watermark, ok := watermarks[group]
+		if !ok {
+			h.logger.Info("no sync watermark for keyword group, using stime", "group", group, "since", stime)
+			continue
+		}
+		since := watermark.Add(-h.watermarkOverlap)
+		if since.After(etime) {
+			since = etime
+		}
+		groupSince[group] = since
+		h.logger.Info("continuing keyword group from sync watermark", "group", group, "watermark", watermark, "since", since)
+	}
+	return groupSince, nil
+}
+
+// Run searches commits of every keyword group from every source in the window of the run, and saves the ones
+// containing profanity. Commits flow through a pipeline of stages connected by channels: search, pre-filter,
+// evaluation and write. Search waits while evaluation is behind, so at most a few pages are held in memory.
+// Progress is checkpointed to the run as pages get done, so that a failed or killed run can be resumed.
+// Once stop is done, no more pages are searched and the run ends after the ones searched so far are done.
+// Cancelling ctx stops the run right away.
+func (h *syncHandler) Run(ctx, stop context.Context, record *model.SyncRun) error {
+	run := newSyncRun(record)
+	tracker := newCheckpointTracker(ctx, h.logger, h.repo, record)
+	defer func() {
+		h.logger.Info("sync summary", run.attrs()...)
+	}()
+	h.logger.Info("starting sync run", "run_id", record.ID, "since", record.Since, "until", record.Until)
+	start := time.Now()
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go h.heartbeat(heartbeatCtx, record.ID)
+
+	g, ctx := errgroup.WithContext(ctx)
+	searchCtx, cancelSearch := context.WithCancel(ctx)
+	defer cancelSearch()
+	unregister := context.AfterFunc(stop, cancelSearch)
+	defer unregister()
+	pages := make(chan pageJob, 1)
+	candidates := make(chan evalJob, 2*h.evaluatorConcurrency)
+	flagged := make(chan flaggedCommit, h.writeBatchSize)
+	writerDone := make(chan struct{})
+
+	g.Go(func() error {
+		defer close(pages)
+		err := h.search(searchCtx, run, tracker, record, pages)
+		if err != nil && ctx.Err() == nil && stop.Err() != nil {
+			h.logger.Info("stopping sync run after pages searched so far", "run_id", record.ID)
+			run.stopped = true
+			return nil
+		}
+		return err
+	})
+	g.Go(func() error {
+		defer close(candidates)
+		return h.prefilter(ctx, run, tracker, pages, candidates)
+	})
+	var evaluators sync.WaitGroup
+	for range h.evaluatorConcurrency {
+		evaluators.Add(1)
+		g.Go(func() error {
+			defer evaluators.Done()
+			return h.evaluate(ctx, run, tracker, candidates, flagged, writerDone)
+		})
+	}
+	go func() {
+		evaluators.Wait()
+		close(flagged)
+	}()
+	g.Go(func() error {
+		defer close(writerDone)
+		return h.write(ctx, run, tracker, flagged)
+	})
+
+	err := g.Wait()
+	h.logger.Info("sync finished", "run_id", record.ID, "elapsed", time.Since(start).Round(time.Millisecond), "evaluator_concurrency", h.evaluatorConcurrency)
+
+	status, errMsg := model.SyncRunCompleted, ""
+	switch {
+	case err != nil:
+		status, errMsg = model.SyncRunFailed, err.Error()
+		h.logger.Error("sync run failed; it can be resumed with --resume", "run_id", record.ID, "err", err)
+	case run.stopped:
+		status = model.SyncRunStopped
+		h.logger.Info("sync run stopped; it can be resumed with --resume", "run_id", record.ID)
+	}
+	if err := h.repo.SetSyncRunStatus(context.WithoutCancel(ctx), record.ID, status, errMsg); err != nil {
+		h.logger.Error("failed to update sync run status", "run_id", record.ID, "err", err)
+	}
+	return err
+}
+
+// search is the first stage; it yields pages found by the sources, from the checkpoint of the run.
+// Pages done before the checkpoint are searched again to get to it, but not passed on.
+func (h *syncHandler) search(ctx context.Context, run *syncRun, tracker *checkpointTracker, record *model.SyncRun, out chan<- pageJob) error {
+	checkpoint := record.Checkpoint
+	for gi, group := range h.keywordGroups {
+		q := source.Query{
+			Group: group,
+			Since: record.SinceFor(group.Name),
+			Until: record.Until,
+		}
+		for si, src := range h.sources {
+			if checkpoint.Before(gi, si) {
+				continue
+			}
+			skip := 0
+			if gi == checkpoint.Group && si == checkpoint.Source {
+				skip = checkpoint.Page
 			}
-			searched, err := h.githubCli.SearchCommits(context.Background(), h.searchKeywords[i:min(i+5, len(h.searchKeywords))], opts...)
+			h.logger.Info("syncing commits", "source", src.Name(), "group", group.Name, "skipped_pages", skip)
+			pos := model.SyncCheckpoint{Group: gi, Source: si}
+			busy := time.Now()
+			err := src.Search(ctx, q, func(page source.Page) error {
+				pos.Page++
+				if pos.Page <= skip {
+					return nil
+				}
+				run.search.in.Add(1)
+				run.search.out.Add(int64(len(page.Commits)))
+				run.search.track(busy)
+				select {
+				case out <- pageJob{src: src, page: page, tracked: tracker.add(pos)}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				busy = time.Now()
+				return nil
+			})
+			run.search.track(busy)
 			if err != nil {
-				h.logger.Error("failed to search commits", "err", err)
+				if ctx.Err() == nil {
+					h.logger.Error("failed to sync commits from source", "source", src.Name(), "group", group.Name, "err", err)
+				}
 				return err
 			}
-			if len(searched.Items) == 0 {
-				h.logger.Info("no more commits found, ending sync")
-				break
+
+			// checkpoint moves on to the next source once pages searched so far are done
+			next := model.SyncCheckpoint{Group: gi, Source: si + 1}
+			if next.Source == len(h.sources) {
+				next = model.SyncCheckpoint{Group: gi + 1}
 			}
-			h.logger.Debug("fetched commits from github", "page", searchPage, "items", len(searched.Items), "total_count", searched.TotalCount, "incomplete_results", searched.IncompleteResults)
+			tracker.done(tracker.add(next), "")
+		}
+	}
+	return nil
+}
 
-			var commits []model.Commit
-			var inserted int
-			for _, c := range searched.Items {
-				if shaMap[c.SHA] {
-					h.logger.Info("skipping duplicate commit", "commit_sha", c.SHA)
-					continue
-				}
-				shaMap[c.SHA] = true
-				if len(c.Commit.Message) > h.maxCommitLength {
-					h.logger.Info("skipping commit with message exceeding max length", "commit_sha", c.SHA, "message_length", len(c.Commit.Message))
-					continue
-				}
-				h.logger.Debug("processing commit", "commit_sha", c.SHA, "commit_message", c.Commit.Message)
+// prefilter is the second stage; it drops duplicate, oversized and automated commits, and links commits already
+// stored from another repository, so that only new commits are evaluated. Copies of a commit that is being evaluated
+// are dropped too, and their repositories are added when it is stored.
+func (h *syncHandler) prefilter(ctx context.Context, run *syncRun, tracker *checkpointTracker, in <-chan pageJob, out chan<- evalJob) error {
+	for job := range in {
+		busy := time.Now()
+		src := job.src
+		run.prefilter.in.Add(int64(len(job.page.Commits)))
 
-				sentiment, err := h.evaluator.Evaluate(context.Background(), c.Commit.Message)
-				if err != nil {
-					h.logger.Error("failed to evaluate sentiment", "err", err, "commit_sha", c.SHA)
-					return err
-				}
-				h.logger.Debug("evaluated sentiment for commit", "commit_sha", c.SHA, "sentiment_score", sentiment.Score)
-				if !sentiment.ContainsProfanity {
-					h.logger.Info("commit does not contain profanity", "commit_sha", c.SHA, "message", c.Commit.Message)
-					continue
-				}
+		var candidates []source.Commit
+		for _, c := range job.page.Commits {
+			key := src.Name() + ":" + c.SHA
+			if run.resumed[key] {
+				h.logger.Debug("skipping commit evaluated before resume", "source", src.Name(), "commit_sha", c.SHA)
+				run.resumedSkipped++
+				continue
+			}
+			// copies of stored commits are passed on to be linked
+			if state, ok := run.see(key, c); ok && state != commitStored {
+				h.logger.Info("skipping duplicate commit", "source", src.Name(), "commit_sha", c.SHA)
+				run.duplicates++
+				continue
+			}
+			if len(c.Message) > h.maxCommitLength {
+				h.logger.Info("skipping commit with message exceeding max length", "commit_sha", c.SHA, "message_length", len(c.Message))
+				run.tooLong++
+				run.settle(key, commitDropped)
+				continue
+			}
+			if rule, ok := h.filter.Drop(c); ok {
+				h.logger.Debug("skipping filtered commit", "commit_sha", c.SHA, "rule", rule, "author", c.Author.Username)
+				run.filtered[rule]++
+				run.settle(key, commitDropped)
+				continue
+			}
+			candidates = append(candidates, c)
+		}
+		candidates, err := h.linkStoredCommits(ctx, run, src.Name(), candidates)
+		if err != nil {
+			h.logger.Error("failed to link stored commits", "err", err)
+			return err
+		}
+		run.prefilter.track(busy)
 
-				commits = append(commits, model.Commit{
-					ID:      fmt.Sprintf("%d:%s", c.Commit.Author.Date.Unix(), c.SHA[:7]),
-					SHA:     c.SHA,
-					URL:     c.HTMLURL,
-					Message: c.Commit.Message,
-					Author: model.Author{
-						Username:  c.AuthorMeta.Login,
-						AvatarURL: c.AuthorMeta.AvatarURL,
-					},
-					Time: c.Commit.Author.Date,
-					Sentiment: model.Sentiment{
-						Score: sentiment.Score,
-						Model: sentiment.Model,
-					},
-				})
-				inserted++
-			}
-
-			if len(commits) > 0 {
-				if err := h.repo.PutCommits(context.Background(), commits); err != nil {
-					h.logger.Error("failed to put commits to db", "err", err)
+		tracker.hold(job.tracked, len(candidates))
+		for _, c := range candidates {
+			select {
+			case out <- evalJob{src: src, commit: c, tracked: job.tracked}:
+				run.prefilter.out.Add(1)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		tracker.done(job.tracked, "")
+	}
+	return nil
+}
+
+// linkStoredCommits adds repositories of commits that are already stored, e.g. found in another fork or mirror
+// during an earlier run, to the stored commits instead of evaluating them again.
+// It returns the commits that are not stored yet.
+func (h *syncHandler) linkStoredCommits(ctx context.Context, run *syncRun, sourceName string, commits []source.Commit) ([]source.Commit, error) {
+	if len(commits) == 0 {
+		return nil, nil
+	}
+	shas := make([]string, 0, len(commits))
+	fingerprints := make([]string, 0, len(commits))
+	for _, c := range commits {
+		shas = append(shas, c.SHA)
+		if fp := model.NewCommitFingerprint(c.AuthorEmail, c.Time, c.Message); fp != "" {
+			fingerprints = append(fingerprints, fp)
+		}
+	}
+	ids, err := h.repo.FindCommitIDs(ctx, shas, fingerprints)
+	if err != nil {
+		return nil, err
+	}
+
+	var rest []source.Commit
+	links := make(map[string][]string)
+	for _, c := range commits {
+		id, ok := ids[c.SHA]
+		if fp := model.NewCommitFingerprint(c.AuthorEmail, c.Time, c.Message); !ok && fp != "" {
+			id, ok = ids[fp]
+		}
+		if !ok {
+			rest = append(rest, c)
+			continue
+		}
+		h.logger.Debug("linking commit to stored commit", "commit_sha", c.SHA, "commit_id", id)
+		run.linked++
+		if c.Repository != nil {
+			links[id] = append(links[id], c.Repository.FullName)
+		}
+		// copies found on the same page
+		if copies := run.settle(sourceName+":"+c.SHA, commitStored); len(copies) > 0 {
+			links[id] = append(links[id], copies...)
+		}
+	}
+	return rest, h.repo.LinkCommitRepositories(ctx, links)
+}
+
+// evaluate is the third stage, run by several workers; it passes on commits containing profanity.
+// Results are handed to the writer even after cancellation, as long as the writer is running.
+func (h *syncHandler) evaluate(ctx context.Context, run *syncRun, tracker *checkpointTracker, in <-chan evalJob, out chan<- flaggedCommit, writerDone <-chan struct{}) error {
+	for {
+		var job evalJob
+		select {
+		case j, ok := <-in:
+			if !ok {
+				return nil
+			}
+			job = j
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		c := job.commit
+		run.evaluate.in.Add(1)
+		h.logger.Debug("processing commit", "source", job.src.Name(), "commit_sha", c.SHA, "commit_message", c.Message)
+
+		busy := time.Now()
+		sentiment, err := h.evaluator.Evaluate(ctx, c.Message)
+		run.evaluate.track(busy)
+		if err != nil {
+			h.logger.Error("failed to evaluate sentiment", "err", err, "commit_sha", c.SHA)
+			return err
+		}
+		h.logger.Debug("evaluated sentiment for commit", "commit_sha", c.SHA, "sentiment_score", sentiment.Score)
+		if !sentiment.ContainsProfanity {
+			h.logger.Info("commit does not contain profanity", "commit_sha", c.SHA, "message", c.Message)
+			// copies found meanwhile are dropped with it, since clean commits aren't stored
+			run.settle(job.src.Name()+":"+c.SHA, commitDropped)
+			tracker.done(job.tracked, job.src.Name()+":"+c.SHA)
+			continue
+		}
+
+		select {
+		case out <- flaggedCommit{src: job.src, commit: newCommit(job.src.Name(), c, sentiment), tracked: job.tracked}:
+			run.evaluate.out.Add(1)
+		case <-writerDone:
+			return nil
+		}
+	}
+}
+
+// write is the last stage; it enriches and saves flagged commits in batches per source.
+// Batches are flushed when full, every writeFlushInterval, and once evaluation is done. Commits evaluated before
+// cancellation are still saved.
+func (h *syncHandler) write(ctx context.Context, run *syncRun, tracker *checkpointTracker, in <-chan flaggedCommit) error {
+	sources := make(map[string]source.CommitSource)
+	batches := make(map[string][]flaggedCommit)
+	flush := func(name string) error {
+		batch := batches[name]
+		if len(batch) == 0 {
+			return nil
+		}
+		commits := make([]model.Commit, 0, len(batch))
+		for _, c := range batch {
+			c.commit.Repositories = append(c.commit.Repositories, run.copies(name+":"+c.commit.SHA)...)
+			commits = append(commits, c.commit)
+		}
+		busy := time.Now()
+		defer run.write.track(busy)
+		// enrichment is skipped once cancelled, since it may wait for rate limits
+		if enricher, ok := sources[name].(source.Enricher); ok && ctx.Err() == nil {
+			// sources are shared by runs of serve, so cost is counted per call of Enrich
+			coster, hasCost := enricher.(graphqlCoster)
+			var costBefore int64
+			if hasCost {
+				costBefore = coster.GraphQLCost()
+			}
+			err := enricher.Enrich(ctx, commits)
+			if hasCost {
+				run.graphqlCost += coster.GraphQLCost() - costBefore
+			}
+			if err != nil && ctx.Err() == nil {
+				h.logger.Error("failed to enrich commits", "err", err)
+				return err
+			}
+		}
+		if err := h.repo.PutCommits(context.WithoutCancel(ctx), commits); err != nil {
+			h.logger.Error("failed to put commits to db", "err", err)
+			return err
+		}
+		// copies found while writing are added by writing the commits again, now that they are stored
+		var late []model.Commit
+		for _, c := range commits {
+			if repositories := run.settle(name+":"+c.SHA, commitStored); len(repositories) > 0 {
+				c.Repositories = repositories
+				late = append(late, c)
+			}
+		}
+		if len(late) > 0 {
+			if err := h.repo.PutCommits(context.WithoutCancel(ctx), late); err != nil {
+				h.logger.Error("failed to put commits to db", "err", err)
+				return err
+			}
+		}
+		run.write.out.Add(int64(len(commits)))
+		h.logger.Info("inserted commits to database", "source", name, "commits_inserted", len(commits))
+		for _, c := range batch {
+			tracker.done(c.tracked, name+":"+c.commit.SHA)
+		}
+		batches[name] = nil
+		return nil
+	}
+	flushAll := func() error {
+		for name := range batches {
+			if err := flush(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(writeFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case c, ok := <-in:
+			if !ok {
+				return flushAll()
+			}
+			run.write.in.Add(1)
+			name := c.src.Name()
+			sources[name] = c.src
+			batches[name] = append(batches[name], c)
+			if len(batches[name]) >= h.writeBatchSize {
+				if err := flush(name); err != nil {
 					return err
 				}
-				h.logger.Info("inserted commits to database", "page", searchPage, "commits_found", len(searched.Items), "commits_inserted", inserted)
-			} else {
-				h.logger.Info("no new commits to insert for this page", "page", searchPage)
 			}
-			searchPage++
-
-			if h.rateLimitWait > 0 {
-				h.logger.Info("waiting for rate limit", "duration", h.rateLimitWait.String())
-				time.Sleep(h.rateLimitWait)
+		case <-ticker.C:
+			if err := flushAll(); err != nil {
+				return err
 			}
 		}
 	}
+}
+
+// graphqlCoster is implemented by sources that use GraphQL rate limit points for enrichment.
+type graphqlCoster interface {
+	// GraphQLCost returns the points used so far.
+	GraphQLCost() int64
+}
+
+// writeFlushInterval is the longest time flagged commits wait for their batch to fill up before being written.
+const writeFlushInterval = 10 * time.Second
+
+// heartbeat keeps the update time of a running sync run fresh, so that it isn't taken for a dead one and resumed.
+func (h *syncHandler) heartbeat(ctx context.Context, id string) {
+	ticker := time.NewTicker(h.leaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := h.repo.TouchSyncRun(ctx, id); err != nil && ctx.Err() == nil {
+			h.logger.Warn("failed to update sync run", "run_id", id, "err", err)
+		}
+	}
+}
 
-	return nil
+// pageJob is a page of commits found by a source.
+type pageJob struct {
+	src     source.CommitSource
+	page    source.Page
+	tracked *trackedPage
+}
+
+// evalJob is a commit waiting for sentiment evaluation.
+type evalJob struct {
+	src     source.CommitSource
+	commit  source.Commit
+	tracked *trackedPage
+}
+
+// flaggedCommit is a commit evaluated to contain profanity, waiting to be written.
+type flaggedCommit struct {
+	src     source.CommitSource
+	commit  model.Commit
+	tracked *trackedPage
+}
+
+// stageMetrics measures a pipeline stage. Busy time excludes waiting on other stages, and is summed over workers.
+type stageMetrics struct {
+	in   atomic.Int64
+	out  atomic.Int64
+	busy atomic.Int64 // nanoseconds
+}
+
+func (m *stageMetrics) track(start time.Time) {
+	m.busy.Add(int64(time.Since(start)))
+}
+
+func (m *stageMetrics) attr(name string) slog.Attr {
+	return slog.Group(name, "in", m.in.Load(), "out", m.out.Load(), "busy", time.Duration(m.busy.Load()).Round(time.Millisecond))
+}
+
+// syncRun is the state of a single sync run.
+type syncRun struct {
+	search    stageMetrics // pages in, commits out
+	prefilter stageMetrics
+	evaluate  stageMetrics
+	write     stageMetrics // commits out are the ones written
+
+	stopped bool // whether search was stopped before searching every page; set by the search stage
+
+	// owned by the pre-filter stage
+	resumed        map[string]bool // "source:sha" keys of commits evaluated before the run was resumed
+	resumedSkipped int
+	duplicates     int
+	tooLong        int
+	filtered       map[string]int // filter rule -> number of commits dropped by it
+	linked         int            // already stored commits found in another repository
+
+	graphqlCost int64 // GraphQL rate limit points used for enrichment; owned by the write stage
+
+	mu      sync.Mutex
+	commits map[string]*runCommit // commits seen during the run, by "source:sha" key and by fingerprint
+}
+
+// runCommit is a commit seen during a sync run.
+type runCommit struct {
+	state        commitState
+	repositories []string // repositories of copies found while it is in flight
+}
+
+type commitState int
+
+const (
+	commitInFlight commitState = iota // passed on to be linked or evaluated
+	commitDropped                     // dropped by the pre-filter or evaluated not to contain profanity
+	commitStored
+)
+
+func newSyncRun(record *model.SyncRun) *syncRun {
+	resumed := make(map[string]bool, len(record.Evaluated))
+	for _, key := range record.Evaluated {
+		resumed[key] = true
+	}
+	return &syncRun{
+		resumed:  resumed,
+		filtered: make(map[string]int),
+		commits:  make(map[string]*runCommit),
+	}
+}
+
+// see records a commit found by a source, keyed by its "source:sha" key. If a copy with the same key or fingerprint,
+// e.g. in a fork, has been seen before, it returns the state of that copy, and the repository of the commit is kept
+// to be added to the copy if it is in flight.
+func (r *syncRun) see(key string, c source.Commit) (commitState, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fp := model.NewCommitFingerprint(c.AuthorEmail, c.Time, c.Message)
+	seen, ok := r.commits[key]
+	if !ok && fp != "" {
+		seen, ok = r.commits["fingerprint:"+fp]
+	}
+	if !ok {
+		seen = &runCommit{}
+	}
+	if ok && seen.state == commitInFlight && c.Repository != nil {
+		seen.repositories = append(seen.repositories, c.Repository.FullName)
+	}
+	r.commits[key] = seen
+	if fp != "" {
+		r.commits["fingerprint:"+fp] = seen
+	}
+	return seen.state, ok
+}
+
+// copies returns repositories of copies of an in-flight commit found so far, and forgets them.
+func (r *syncRun) copies(key string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen, ok := r.commits[key]
+	if !ok {
+		return nil
+	}
+	repositories := seen.repositories
+	seen.repositories = nil
+	return repositories
+}
+
+// settle sets the state of a commit once it is no longer in flight, and returns repositories of copies found since
+// the last call of copies.
+func (r *syncRun) settle(key string, state commitState) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen, ok := r.commits[key]
+	if !ok {
+		return nil
+	}
+	repositories := seen.repositories
+	seen.repositories = nil
+	seen.state = state
+	return repositories
+}
+
+func (r *syncRun) attrs() []any {
+	var filtered []any
+	for rule, n := range r.filtered {
+		filtered = append(filtered, slog.Int(rule, n))
+	}
+	return []any{
+		"commits_found", r.search.out.Load(),
+		"duplicates", r.duplicates,
+		"evaluated_before_resume", r.resumedSkipped,
+		"too_long", r.tooLong,
+		slog.Group("filtered", filtered...),
+		"graphql_cost", r.graphqlCost,
+		"linked", r.linked,
+		"evaluated", r.evaluate.in.Load(),
+		"inserted", r.write.out.Load(),
+		r.search.attr("stage_search"),
+		r.prefilter.attr("stage_prefilter"),
+		r.evaluate.attr("stage_evaluate"),
+		r.write.attr("stage_write"),
+	}
 }
@@ -20,5 +20,10 @@ func main() {
 	viper.BindPFlags(flags)
 
 	rootCmd.AddCommand(cmd.Sync())
+	rootCmd.AddCommand(cmd.ScanLocal())
+	rootCmd.AddCommand(cmd.Serve())
+	rootCmd.AddCommand(cmd.Backfill())
+	rootCmd.AddCommand(cmd.Reevaluate())
+	rootCmd.AddCommand(cmd.Migrate())
 	rootCmd.Execute()
 }
@@ -1,15 +1,69 @@
 package model
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Commit struct {
-	ID        string    `json:"-" bson:"_id"`
-	SHA       string    `json:"sha" bson:"sha"`
-	URL       string    `json:"url" bson:"url"`
-	Message   string    `json:"message" bson:"message"`
-	Author    Author    `json:"author" bson:"author"`
-	Time      time.Time `json:"time" bson:"time"`
-	Sentiment Sentiment `json:"sentiment" bson:"sentiment"`
+	ID         string      `json:"-" bson:"_id"`
+	Source     string      `json:"source" bson:"source"` // code hosting service the commit was found on, e.g. "github"
+	SHA        string      `json:"sha" bson:"sha"`
+	URL        string      `json:"url" bson:"url"`
+	Message    string      `json:"message" bson:"message"`
+	Author     Author      `json:"author" bson:"author"`
+	Time       time.Time   `json:"time" bson:"time"`
+	Sentiment  Sentiment   `json:"sentiment" bson:"sentiment"`
+	Repository *Repository `json:"repository,omitempty" bson:"repository,omitempty"`
+	// Repositories are full names of all repositories the commit was found in, e.g. forks and mirrors of Repository.
+	Repositories []string       `json:"repositories,omitempty" bson:"repositories,omitempty"`
+	Fingerprint  string         `json:"-" bson:"fingerprint,omitempty"`             // see NewCommitFingerprint
+	Details      *CommitDetails `json:"details,omitempty" bson:"details,omitempty"` // only set when enrichment is enabled
+	// SentimentHistory are sentiments the commit was scored with before it was re-evaluated, oldest first.
+	SentimentHistory []Sentiment `json:"sentiment_history,omitempty" bson:"sentiment_history,omitempty"`
+}
+
+// commitIDTimeWidth is the number of digits of the time part of commit IDs, enough for times until year 5138.
+const commitIDTimeWidth = 11
+
+// NewCommitID makes commit document ID, which sorts lexically by commit time, then SHA.
+// Time is zero-padded unix seconds; times before the epoch are clamped to it.
+func NewCommitID(t time.Time, sha string) string {
+	return fmt.Sprintf("%0*d:%s", commitIDTimeWidth, max(t.Unix(), 0), strings.ToLower(sha))
+}
+
+// ParseCommitID splits commit document ID made by NewCommitID into commit time and SHA.
+func ParseCommitID(id string) (time.Time, string, error) {
+	sec, sha, ok := strings.Cut(id, ":")
+	if !ok || len(sec) != commitIDTimeWidth {
+		return time.Time{}, "", fmt.Errorf("invalid commit id %q", id)
+	}
+	unix, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil || unix < 0 {
+		return time.Time{}, "", fmt.Errorf("invalid time in commit id %q", id)
+	}
+	if _, err := hex.DecodeString(sha); err != nil || len(sha) < 40 || sha != strings.ToLower(sha) {
+		return time.Time{}, "", fmt.Errorf("invalid sha in commit id %q", id)
+	}
+	return time.Unix(unix, 0), sha, nil
+}
+
+// NewCommitFingerprint identifies a commit by its content, so that copies with rewritten SHA (e.g. in mirrors) are
+// recognized as the same commit. Empty if the author email is unknown.
+func NewCommitFingerprint(authorEmail string, t time.Time, message string) string {
+	if authorEmail == "" {
+		return ""
+	}
+	h := sha256.New()
+	for _, part := range []string{strings.ToLower(authorEmail), strconv.FormatInt(t.Unix(), 10), strings.TrimSpace(message)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 type Author struct {
@@ -21,3 +75,41 @@ type Sentiment struct {
 	Score float64 `json:"score" bson:"score"` // -1.0 (negative) to 1.0 (positive)
 	Model string  `json:"model" bson:"model"` // name of the model used for evaluation
 }
+
+type Repository struct {
+	FullName    string `json:"full_name" bson:"full_name"` // e.g. "owner/repo"
+	Owner       string `json:"owner" bson:"owner"`
+	URL         string `json:"url" bson:"url"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	Fork        bool   `json:"fork" bson:"fork"`
+	Private     bool   `json:"private" bson:"private"`
+	Language    string `json:"language,omitempty" bson:"language,omitempty"` // only set when enrichment is enabled
+	Stars       int    `json:"stars,omitempty" bson:"stars,omitempty"`       // only set when enriched with GraphQL
+}
+
+type CommitDetails struct {
+	Committer    Author       `json:"committer" bson:"committer"`
+	Additions    int          `json:"additions" bson:"additions"`
+	Deletions    int          `json:"deletions" bson:"deletions"`
+	Files        []CommitFile `json:"files,omitempty" bson:"files,omitempty"` // not available when enriched with GraphQL
+	Verification Verification `json:"verification" bson:"verification"`
+	PullRequest  *PullRequest `json:"pull_request,omitempty" bson:"pull_request,omitempty"`
+}
+
+type PullRequest struct {
+	Number int    `json:"number" bson:"number"`
+	Title  string `json:"title" bson:"title"`
+	URL    string `json:"url" bson:"url"`
+}
+
+type CommitFile struct {
+	Filename  string `json:"filename" bson:"filename"`
+	Status    string `json:"status" bson:"status"`
+	Additions int    `json:"additions" bson:"additions"`
+	Deletions int    `json:"deletions" bson:"deletions"`
+}
+
+type Verification struct {
+	Verified bool   `json:"verified" bson:"verified"`
+	Reason   string `json:"reason" bson:"reason"`
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+type SyncRunStatus string
+
+const (
+	SyncRunRunning   SyncRunStatus = "running" // also left on runs that were killed
+	SyncRunCompleted SyncRunStatus = "completed"
+	SyncRunFailed    SyncRunStatus = "failed"
+	SyncRunStopped   SyncRunStatus = "stopped" // stopped gracefully before completion
+)
+
+// SyncRun is a record of a sync run, checkpointed while it runs so that it can be resumed.
+type SyncRun struct {
+	ID     string        `json:"id" bson:"_id"`
+	Status SyncRunStatus `json:"status" bson:"status"`
+	Since  time.Time     `json:"since" bson:"since"` // search window
+	Until  time.Time     `json:"until" bson:"until"`
+	// GroupSince overrides Since for keyword groups, e.g. to continue from their watermarks.
+	GroupSince map[string]time.Time `json:"group_since,omitempty" bson:"group_since,omitempty"`
+	Groups     []string             `json:"groups" bson:"groups"`   // keyword group names, in search order
+	Sources    []string             `json:"sources" bson:"sources"` // source names, in search order
+	Checkpoint SyncCheckpoint       `json:"checkpoint" bson:"checkpoint"`
+	Backfill   bool                 `json:"backfill,omitempty" bson:"backfill,omitempty"` // whether the run syncs a backfill chunk
+	Evaluated  []string             `json:"-" bson:"evaluated"`                           // "source:sha" keys of commits evaluated so far
+	Error      string               `json:"error,omitempty" bson:"error,omitempty"`
+	Attempts   int                  `json:"attempts" bson:"attempts"` // number of times the run was resumed
+	StartedAt  time.Time            `json:"started_at" bson:"started_at"`
+	UpdatedAt  time.Time            `json:"updated_at" bson:"updated_at"` // also kept fresh while the run is running
+}
+
+// SinceFor returns start of the search window of a keyword group.
+func (r *SyncRun) SinceFor(group string) time.Time {
+	if since, ok := r.GroupSince[group]; ok {
+		return since
+	}
+	return r.Since
+}
+
+// SyncWatermark is the end of the search window of the last completed sync of a keyword group.
+type SyncWatermark struct {
+	Group     string    `json:"group" bson:"_id"`
+	Until     time.Time `json:"until" bson:"until"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// SyncCheckpoint is the position up to which a sync run has been processed: pages are searched for every keyword
+// group from every source in turn, and the first Page pages of the source at Source in the group at Group are done.
+type SyncCheckpoint struct {
+	Group  int `json:"group" bson:"group"`   // index of keyword group in SyncRun.Groups
+	Source int `json:"source" bson:"source"` // index of source in SyncRun.Sources
+	Page   int `json:"page" bson:"page"`     // number of pages done
+}
+
+// Before reports whether the search of group and source at given indexes starts before the checkpoint.
+func (c SyncCheckpoint) Before(group, source int) bool {
+	return group < c.Group || group == c.Group && source < c.Source
+}
+
+// BackfillChunk is a part of a backfill range, synced by a sync run of its own.
+type BackfillChunk struct {
+	ID        string        `json:"id" bson:"_id"` // see NewBackfillChunkID
+	Since     time.Time     `json:"since" bson:"since"`
+	Until     time.Time     `json:"until" bson:"until"`
+	RunID     string        `json:"run_id" bson:"run_id"` // latest sync run of the chunk
+	Status    SyncRunStatus `json:"status" bson:"status"`
+	UpdatedAt time.Time     `json:"updated_at" bson:"updated_at"`
+}
+
+// NewBackfillChunkID makes backfill chunk document ID from its window, so that reruns over the same range find it.
+func NewBackfillChunkID(since, until time.Time) string {
+	return fmt.Sprintf("%d-%d", since.Unix(), until.Unix())
+}
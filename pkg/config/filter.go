@@ -0,0 +1,22 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"sb-scanner/pkg/source"
+)
+
+// ReadFilter reads the commit pre-filter from `sync.filter`. Nothing is dropped if it is not configured.
+func ReadFilter(v *viper.Viper) (*source.Filter, error) {
+	var cfg source.FilterConfig
+	if err := v.UnmarshalKey("sync.filter", &cfg); err != nil {
+		return nil, fmt.Errorf("failed to read sync.filter: %w", err)
+	}
+	filter, err := source.NewFilter(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sync.filter: %w", err)
+	}
+	return filter, nil
+}
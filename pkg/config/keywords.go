@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"sb-scanner/pkg/source"
+)
+
+// ReadKeywordGroups reads search keyword groups from `github.search.groups`.
+// Flat `github.search.keywords` list is read as a single group named "default" if no groups are configured.
+// Keywords prefixed with "-" are treated as excluded terms.
+func ReadKeywordGroups(v *viper.Viper) ([]source.KeywordGroup, error) {
+	var groups []source.KeywordGroup
+	if v.IsSet("github.search.groups") {
+		if err := v.UnmarshalKey("github.search.groups", &groups); err != nil {
+			return nil, fmt.Errorf("failed to read github.search.groups: %w", err)
+		}
+	} else if keywords := v.GetStringSlice("github.search.keywords"); len(keywords) > 0 {
+		groups = []source.KeywordGroup{{Name: "default", Keywords: keywords}}
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("no search keywords provided in configuration")
+	}
+
+	names := make(map[string]bool)
+	for i := range groups {
+		g := &groups[i]
+		if g.Name == "" {
+			g.Name = fmt.Sprintf("group-%d", i+1)
+		}
+		if names[g.Name] {
+			return nil, fmt.Errorf("duplicate keyword group name %q", g.Name)
+		}
+		names[g.Name] = true
+
+		var keywords []string
+		for _, k := range g.Keywords {
+			if excluded, ok := strings.CutPrefix(k, "-"); ok {
+				g.Exclude = append(g.Exclude, excluded)
+				continue
+			}
+			keywords = append(keywords, k)
+		}
+		if len(keywords) == 0 {
+			return nil, fmt.Errorf("keyword group %q has no keywords", g.Name)
+		}
+		g.Keywords = keywords
+	}
+
+	return groups, nil
+}
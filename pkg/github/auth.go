@@ -2,101 +2,63 @@ package github
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
+	"crypto/rsa"
 	"net/http"
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
-
-	pkglog "sb-scanner/pkg/logger"
 )
 
+// requiredPermissions are installation permissions used by the client; metadata is needed to see repositories at all.
+var requiredPermissions = map[string]string{
+	"metadata": "read",
+}
+
+// AuthenticatedClient is a GitHub API client authenticated as a GitHub App installation.
+// It is safe for concurrent use.
 type AuthenticatedClient struct {
 	*DefaultClient
 
-	// GitHub App credentials
-	appID          string
-	installationID string
-	keyBytes       []byte // PEM encoded RSA private key
-
-	// issued installation access token
-	token          string
-	tokenExpiresAt time.Time
+	tokenSource *InstallationTokenSource
 }
 
 // Creates authenticated GitHub API client using GitHub App credentials.
-func NewAuthenticatedClient(appID, installationID string, keyBytes []byte) (*AuthenticatedClient, error) {
-	authenticatedCli := &AuthenticatedClient{
-		DefaultClient: &DefaultClient{
-			logger:  pkglog.GetLogger().With("pkg", "github"),
-			httpcli: http.DefaultClient,
-		},
-		appID:          appID,
-		installationID: installationID,
-		keyBytes:       keyBytes,
-	}
-	if err := authenticatedCli.refreshToken(); err != nil {
-		return nil, fmt.Errorf("failed to refresh github auth token: %w", err)
-	}
-
-	return authenticatedCli, nil
-}
-
-func (c *AuthenticatedClient) refreshToken() error {
-	pk, err := jwt.ParseRSAPrivateKeyFromPEM(c.keyBytes)
+// Credentials and installation are validated, so that misconfiguration fails here instead of on the first search.
+func NewAuthenticatedClient(appID, installationID string, pk *rsa.PrivateKey, opts ...ClientOption) (*AuthenticatedClient, error) {
+	tokenSource, err := NewInstallationTokenSource(appID, installationID, pk, opts...)
 	if err != nil {
-		return fmt.Errorf("failed to parse RSA private key: %w", err)
+		return nil, err
 	}
-	now := time.Now().UTC()
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
-		Issuer:    c.appID,
-		IssuedAt:  jwt.NewNumericDate(now.Add(-1 * time.Minute)),
-		ExpiresAt: jwt.NewNumericDate(now.Add(10 * time.Minute)),
-	})
-	signedJWT, err := token.SignedString(pk)
+	inst, err := tokenSource.Validate(context.Background(), requiredPermissions)
 	if err != nil {
-		return fmt.Errorf("failed to sign JWT: %w", err)
+		return nil, err
 	}
-
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://api.github.com/app/installations/%s/access_tokens", c.installationID), nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	tokenSource.cli.logger.Info("validated github app installation", "account", inst.Account.Login, "repository_selection", inst.RepositorySelection)
+	if _, err := tokenSource.Token(context.Background()); err != nil {
+		return nil, err
 	}
-	req.Header.Add("Accept", acceptHeaderValue)
-	req.Header.Add(apiVersionHeaderKey, apiVersionHeaderValue)
-	req.Header.Add("User-Agent", userAgentHeaderValue)
-	req.Header.Add("Authorization", "Bearer "+signedJWT)
 
-	resp, err := c.httpcli.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-	respBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
-	var result accessTokenResponse
-	if err := json.Unmarshal(respBytes, &result); err != nil {
-		return fmt.Errorf("failed to unmarshal response body: %w", err)
-	}
-	c.token = result.Token
-	c.tokenExpiresAt = result.ExpiresAt
-	c.logger.Debug("refreshed github installation access token")
+	// every request sent with this client gets installation token attached by the transport
+	httpcli := *tokenSource.cli.httpcli
+	httpcli.Transport = &Transport{Source: tokenSource, Base: httpcli.Transport}
 
-	return nil
+	return &AuthenticatedClient{
+		DefaultClient: NewDefaultClient(append(opts, WithHTTPClient(&httpcli))...),
+		tokenSource:   tokenSource,
+	}, nil
 }
 
-func (c *AuthenticatedClient) SearchCommits(ctx context.Context, keywords []string, opts ...SearchOption) (SearchResult, error) {
-	// not considering concurrent use for now
-	if time.Until(c.tokenExpiresAt) < 5*time.Minute {
-		if err := c.refreshToken(); err != nil {
-			return SearchResult{}, fmt.Errorf("failed to refresh github auth token: %w", err)
-		}
-	}
-	opts = append(opts, withAuthToken(c.token))
+// TokenSource returns the installation token source used by the client.
+func (c *AuthenticatedClient) TokenSource() *InstallationTokenSource {
+	return c.tokenSource
+}
+
+// HTTPClient returns an http.Client that authenticates requests as the installation.
+func (c *AuthenticatedClient) HTTPClient() *http.Client {
+	return c.httpcli
+}
 
-	return c.DefaultClient.SearchCommits(ctx, keywords, opts...)
+// Creates GitHub API client authenticated with a personal access token.
+func NewTokenClient(token string, opts ...ClientOption) *DefaultClient {
+	base := NewDefaultClient(opts...)
+	httpcli := *base.httpcli
+	httpcli.Transport = &Transport{Source: StaticToken(token), Base: httpcli.Transport}
+	return NewDefaultClient(append(opts, WithHTTPClient(&httpcli))...)
 }
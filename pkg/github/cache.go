@@ -0,0 +1,145 @@
+package github
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a cached response body with the validators used for conditional requests.
+type CacheEntry struct {
+	ETag         string    `json:"etag" bson:"etag"`
+	LastModified string    `json:"last_modified" bson:"last_modified"`
+	Body         []byte    `json:"body" bson:"body"`
+	StoredAt     time.Time `json:"stored_at" bson:"stored_at"`
+}
+
+// Cache stores responses by request URL. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the entry for the key; ok is false if there is none.
+	Get(ctx context.Context, key string) (entry CacheEntry, ok bool, err error)
+	Set(ctx context.Context, key string, entry CacheEntry) error
+}
+
+// MemoryCache is a Cache that lives as long as the process. It holds up to maxEntries entries, evicting the least
+// recently used one beyond that, and entries expire ttl after they were stored.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	entries    map[string]*list.Element // of *memoryCacheItem, most recently used first
+	lru        *list.List
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry CacheEntry
+}
+
+func NewMemoryCache(maxEntries int, ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (CacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return CacheEntry{}, false, nil
+	}
+	item := el.Value.(*memoryCacheItem)
+	if time.Since(item.entry.StoredAt) > c.ttl {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return CacheEntry{}, false, nil
+	}
+	c.lru.MoveToFront(el)
+	return item.entry, true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, entry CacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*memoryCacheItem).entry = entry
+		c.lru.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.lru.PushFront(&memoryCacheItem{key: key, entry: entry})
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheItem).key)
+	}
+	return nil
+}
+
+// DiskCache is a Cache that stores an entry per file in a directory.
+type DiskCache struct {
+	dir string
+}
+
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *DiskCache) Get(ctx context.Context, key string) (CacheEntry, bool, error) {
+	b, err := os.ReadFile(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return CacheEntry{}, false, nil
+	}
+	if err != nil {
+		return CacheEntry{}, false, fmt.Errorf("failed to read cache file: %w", err)
+	}
+	var e CacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return CacheEntry{}, false, fmt.Errorf("failed to unmarshal cache file: %w", err)
+	}
+	return e, true, nil
+}
+
+func (c *DiskCache) Set(ctx context.Context, key string, entry CacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	// write to temp file and rename, so that concurrent readers never see a partial file
+	f, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(f.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("failed to rename cache file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned for non-2xx responses from the GitHub API.
+type APIError struct {
+	StatusCode       int              `json:"-"`
+	RequestID        string           `json:"-"` // X-GitHub-Request-Id header; useful when contacting GitHub support
+	Message          string           `json:"message"`
+	DocumentationURL string           `json:"documentation_url"`
+	Errors           []APIErrorDetail `json:"errors"`
+}
+
+type APIErrorDetail struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+// UnmarshalJSON accepts plain strings as well, since some endpoints return error details that way.
+func (d *APIErrorDetail) UnmarshalJSON(b []byte) error {
+	var msg string
+	if err := json.Unmarshal(b, &msg); err == nil {
+		d.Message = msg
+		return nil
+	}
+	type detail APIErrorDetail
+	return json.Unmarshal(b, (*detail)(d))
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{}
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	apiErr.StatusCode = resp.StatusCode
+	apiErr.RequestID = resp.Header.Get("X-GitHub-Request-Id")
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("github api error: %d %s", e.StatusCode, e.Message)
+	var details []string
+	for _, d := range e.Errors {
+		switch {
+		case d.Message != "":
+			details = append(details, d.Message)
+		case d.Field != "":
+			details = append(details, fmt.Sprintf("%s.%s: %s", d.Resource, d.Field, d.Code))
+		}
+	}
+	if len(details) > 0 {
+		msg += " (" + strings.Join(details, "; ") + ")"
+	}
+	if e.RequestID != "" {
+		msg += ", request id: " + e.RequestID
+	}
+	return msg
+}
+
+// IsValidation reports whether the request was rejected as invalid (e.g. malformed search query).
+func (e *APIError) IsValidation() bool {
+	return e.StatusCode == http.StatusUnprocessableEntity || e.StatusCode == http.StatusBadRequest
+}
+
+// IsAuth reports whether the request failed due to bad credentials or missing permissions.
+func (e *APIError) IsAuth() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// IsServerError reports whether GitHub failed to serve the request, in which case retrying later may help.
+func (e *APIError) IsServerError() bool {
+	return e.StatusCode >= 500
+}
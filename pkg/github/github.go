@@ -7,32 +7,107 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	pkglog "sb-scanner/pkg/logger"
 )
 
 type Client interface {
-	SearchCommits(ctx context.Context, keywords []string, opts ...SearchOption) (SearchResult, error)
+	SearchCommits(ctx context.Context, query Query, opts ...SearchOption) (SearchResult, error)
+	GetCommit(ctx context.Context, owner, repo, sha string) (CommitDetail, error)
+	GetRepository(ctx context.Context, owner, repo string) (Repository, error)
+	LookupCommits(ctx context.Context, owner, repo string, shas []string) (CommitLookup, error)
 }
 
 type DefaultClient struct {
 	logger  *slog.Logger
 	httpcli *http.Client
+	baseURL string
+
+	rateLimitMaxRetries int
+	rateLimitMaxWait    time.Duration
+	cache               Cache // nil if caching is disabled
+
+	mu         sync.Mutex
+	rateLimits map[string]rateLimit // last known rate limit state by resource
 }
 
 // Creates unauthenticated GitHub API client.
 // Note: Unauthenticated requests are subject to much lower rate limits.
-func NewDefaultClient() *DefaultClient {
+func NewDefaultClient(opts ...ClientOption) *DefaultClient {
+	ov := &clientOptionValues{
+		BaseURL:             defaultBaseURL,
+		HTTPClient:          http.DefaultClient,
+		RateLimitMaxRetries: defaultRateLimitMaxRetries,
+		RateLimitMaxWait:    defaultRateLimitMaxWait,
+	}
+	for _, opt := range opts {
+		opt(ov)
+	}
 	return &DefaultClient{
-		logger:  pkglog.GetLogger().With("pkg", "github"),
-		httpcli: http.DefaultClient,
+		logger:              pkglog.GetLogger().With("pkg", "github"),
+		httpcli:             ov.HTTPClient,
+		baseURL:             strings.TrimSuffix(ov.BaseURL, "/"),
+		rateLimitMaxRetries: ov.RateLimitMaxRetries,
+		rateLimitMaxWait:    ov.RateLimitMaxWait,
+		cache:               ov.Cache,
+		rateLimits:          make(map[string]rateLimit),
+	}
+}
+
+type ClientOption func(o *clientOptionValues)
+
+type clientOptionValues struct {
+	BaseURL             string
+	HTTPClient          *http.Client
+	RateLimitMaxRetries int
+	RateLimitMaxWait    time.Duration
+	Cache               Cache
+}
+
+// WithCache enables conditional requests; GET responses are stored in the cache and served from it on 304 Not Modified.
+func WithCache(cache Cache) ClientOption {
+	return func(o *clientOptionValues) {
+		o.Cache = cache
+	}
+}
+
+// WithBaseURL sets the API base URL, e.g. "https://github.example.com/api/v3" for GitHub Enterprise Server.
+func WithBaseURL(url string) ClientOption {
+	return func(o *clientOptionValues) {
+		o.BaseURL = url
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to send requests.
+func WithHTTPClient(cli *http.Client) ClientOption {
+	return func(o *clientOptionValues) {
+		o.HTTPClient = cli
+	}
+}
+
+// WithRateLimitMaxRetries sets how many times a rate limited request is retried before giving up.
+func WithRateLimitMaxRetries(n int) ClientOption {
+	return func(o *clientOptionValues) {
+		o.RateLimitMaxRetries = n
+	}
+}
+
+// WithRateLimitMaxWait sets the longest the client will wait for a rate limit to reset.
+// If the reset is further away than this, ErrRateLimited is returned immediately.
+func WithRateLimitMaxWait(d time.Duration) ClientOption {
+	return func(o *clientOptionValues) {
+		o.RateLimitMaxWait = d
 	}
 }
 
 const (
+	defaultBaseURL = "https://api.github.com"
+
 	acceptHeaderValue     = "application/vnd.github+json"
 	apiVersionHeaderKey   = "X-Github-Api-Version"
 	apiVersionHeaderValue = "2022-11-28"
@@ -46,8 +121,6 @@ type searchOptionValues struct {
 	Size      *int
 	StartTime *time.Time
 	EndTime   *time.Time
-
-	authToken *string
 }
 
 func WithPage(page int) SearchOption {
@@ -74,23 +147,17 @@ func WithEndTime(t time.Time) SearchOption {
 	}
 }
 
-// withAuthToken sets a personal access token for authentication; for internal use only
-func withAuthToken(token string) SearchOption {
-	return func(o *searchOptionValues) {
-		o.authToken = &token
-	}
-}
-
-func (c *DefaultClient) SearchCommits(ctx context.Context, keywords []string, opts ...SearchOption) (SearchResult, error) {
-	if len(keywords) > 5 {
-		return SearchResult{}, fmt.Errorf("maximum 5 keywords are allowed")
+// SearchCommits searches commits matching the query. Use Query.Split for queries over GitHub search limits.
+func (c *DefaultClient) SearchCommits(ctx context.Context, query Query, opts ...SearchOption) (SearchResult, error) {
+	if err := query.Validate(); err != nil {
+		return SearchResult{}, fmt.Errorf("invalid search query: %w", err)
 	}
 	ov := &searchOptionValues{}
 	for _, opt := range opts {
 		opt(ov)
 	}
 
-	searchQ := strings.Join(keywords, " OR ")
+	searchQ := query.String()
 	if ov.StartTime != nil {
 		if ov.EndTime != nil {
 			searchQ += fmt.Sprintf(" author-date:%s..%s", ov.StartTime.Format(time.RFC3339), ov.EndTime.Format(time.RFC3339))
@@ -104,9 +171,9 @@ func (c *DefaultClient) SearchCommits(ctx context.Context, keywords []string, op
 	}
 	c.logger.Debug("search query", "q", searchQ)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/search/commits", nil)
+	req, err := c.newRequest(ctx, http.MethodGet, "/search/commits")
 	if err != nil {
-		return SearchResult{}, fmt.Errorf("failed to create request: %w", err)
+		return SearchResult{}, err
 	}
 	q := req.URL.Query()
 	q.Add("q", searchQ)
@@ -119,26 +186,200 @@ func (c *DefaultClient) SearchCommits(ctx context.Context, keywords []string, op
 	}
 	req.URL.RawQuery = q.Encode()
 	c.logger.Debug("search request query string", "query", req.URL.RawQuery)
-	req.Header.Add("Accept", acceptHeaderValue)
-	req.Header.Add(apiVersionHeaderKey, apiVersionHeaderValue)
-	req.Header.Add("User-Agent", userAgentHeaderValue)
-	if ov.authToken != nil {
-		req.Header.Add("Authorization", "Bearer "+*(ov.authToken))
+
+	_, respBytes, err := c.do(req)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	var result SearchResult
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return SearchResult{}, fmt.Errorf("failed to unmarshal response body: %w", err)
 	}
 
-	resp, err := c.httpcli.Do(req)
+	return result, nil
+}
+
+// GetCommit gets a single commit with its stats, changed files and signature verification.
+func (c *DefaultClient) GetCommit(ctx context.Context, owner, repo, sha string) (CommitDetail, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/commits/%s", url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(sha)))
 	if err != nil {
-		return SearchResult{}, fmt.Errorf("failed to send request: %w", err)
+		return CommitDetail{}, err
 	}
-	defer resp.Body.Close()
-	respBytes, err := io.ReadAll(resp.Body)
+	_, respBytes, err := c.do(req)
 	if err != nil {
-		return SearchResult{}, fmt.Errorf("failed to read response body: %w", err)
+		return CommitDetail{}, err
 	}
-	var result SearchResult
+	var result CommitDetail
 	if err := json.Unmarshal(respBytes, &result); err != nil {
-		return SearchResult{}, fmt.Errorf("failed to unmarshal response body: %w", err)
+		return CommitDetail{}, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetRepository gets repository metadata.
+func (c *DefaultClient) GetRepository(ctx context.Context, owner, repo string) (Repository, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s", url.PathEscape(owner), url.PathEscape(repo)))
+	if err != nil {
+		return Repository{}, err
+	}
+	_, respBytes, err := c.do(req)
+	if err != nil {
+		return Repository{}, err
+	}
+	var result Repository
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return Repository{}, fmt.Errorf("failed to unmarshal response body: %w", err)
 	}
 
 	return result, nil
 }
+
+// newRequest creates a request to the API path with common headers set.
+func (c *DefaultClient) newRequest(ctx context.Context, method, path string) (*http.Request, error) {
+	return c.newRequestURL(ctx, method, c.baseURL+path, nil)
+}
+
+// newRequestURL creates a request to the URL with common headers set.
+// Body should be one of the types http.NewRequest can rewind (e.g. *bytes.Reader), so that it can be resent on retry.
+func (c *DefaultClient) newRequestURL(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("Accept", acceptHeaderValue)
+	req.Header.Add(apiVersionHeaderKey, apiVersionHeaderValue)
+	req.Header.Add("User-Agent", userAgentHeaderValue)
+	return req, nil
+}
+
+// do sends the request and returns the response with its body read.
+// Non-2xx responses are returned as *APIError.
+// If caching is enabled, GET requests are sent as conditional requests and served from cache if not modified.
+// Rate limited requests are retried after waiting for the limit to reset, up to the configured retries and wait.
+func (c *DefaultClient) do(req *http.Request) (*http.Response, []byte, error) {
+	ctx := req.Context()
+	resource := resourceForPath(req.URL.Path)
+
+	cacheKey := req.URL.String()
+	var cached CacheEntry
+	var hasCached bool
+	if c.cache != nil && req.Method == http.MethodGet {
+		var err error
+		cached, hasCached, err = c.cache.Get(ctx, cacheKey)
+		if err != nil {
+			c.logger.Warn("failed to get cached response", "err", err, "url", cacheKey)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.waitRateLimit(ctx, resource); err != nil {
+			return nil, nil, err
+		}
+
+		attemptReq := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			attemptReq.Body = body
+		}
+		if hasCached {
+			if cached.ETag != "" {
+				attemptReq.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				attemptReq.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+		resp, err := c.httpcli.Do(attemptReq)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		respBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		if r := resp.Header.Get("X-RateLimit-Resource"); r != "" {
+			resource = r
+		}
+		c.updateRateLimit(resource, resp.Header)
+
+		if hasCached && resp.StatusCode == http.StatusNotModified {
+			c.logger.Debug("serving cached response", "url", cacheKey)
+			return resp, cached.Body, nil
+		}
+		wait, rlErr := checkRateLimited(resp, respBytes, resource, attempt)
+		if rlErr == nil {
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				return nil, nil, newAPIError(resp, respBytes)
+			}
+			c.storeCache(ctx, req, cacheKey, resp, respBytes)
+			return resp, respBytes, nil
+		}
+		if attempt >= c.rateLimitMaxRetries || wait > c.rateLimitMaxWait {
+			return nil, nil, rlErr
+		}
+		c.logger.Warn("rate limited by github; waiting before retry", "resource", resource, "secondary", rlErr.Secondary, "wait", wait.String(), "attempt", attempt+1)
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// waitRateLimit blocks until the resource is expected to have quota left, based on the last seen response headers.
+func (c *DefaultClient) waitRateLimit(ctx context.Context, resource string) error {
+	rl, ok := c.rateLimit(resource)
+	if !ok || rl.remaining > 0 {
+		return nil
+	}
+	wait := time.Until(rl.resetAt) + time.Second
+	if wait <= 0 {
+		return nil
+	}
+	if wait > c.rateLimitMaxWait {
+		return &RateLimitError{Resource: resource, ResetAt: rl.resetAt}
+	}
+	c.logger.Info("rate limit quota exhausted; waiting for reset", "resource", resource, "wait", wait.String())
+	return sleepContext(ctx, wait)
+}
+
+// rateLimit returns the last known rate limit state of the resource.
+func (c *DefaultClient) rateLimit(resource string) (rateLimit, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rl, ok := c.rateLimits[resource]
+	return rl, ok
+}
+
+func (c *DefaultClient) updateRateLimit(resource string, h http.Header) {
+	rl, ok := parseRateLimit(h)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	c.rateLimits[resource] = rl
+	c.mu.Unlock()
+	c.logger.Debug("rate limit state", "resource", resource, "limit", rl.limit, "remaining", rl.remaining, "reset_at", rl.resetAt)
+}
+
+// storeCache stores GET response bodies that can be revalidated with conditional requests.
+func (c *DefaultClient) storeCache(ctx context.Context, req *http.Request, key string, resp *http.Response, body []byte) {
+	if c.cache == nil || req.Method != http.MethodGet {
+		return
+	}
+	entry := CacheEntry{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         body,
+		StoredAt:     time.Now(),
+	}
+	if entry.ETag == "" && entry.LastModified == "" {
+		return
+	}
+	if err := c.cache.Set(ctx, key, entry); err != nil {
+		c.logger.Warn("failed to store cached response", "err", err, "url", key)
+	}
+}
@@ -0,0 +1,214 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxCommitsPerLookup limits commits looked up in a single query to keep its cost and response size reasonable.
+const MaxCommitsPerLookup = 50
+
+// GraphQLError is returned when a GraphQL query fails; GitHub reports these with 200 status.
+type GraphQLError struct {
+	Errors []GraphQLErrorItem
+}
+
+type GraphQLErrorItem struct {
+	Type    string `json:"type"` // e.g. "NOT_FOUND", "RATE_LIMITED"
+	Message string `json:"message"`
+}
+
+func (e *GraphQLError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, item := range e.Errors {
+		msgs = append(msgs, item.Message)
+	}
+	return "github graphql error: " + strings.Join(msgs, "; ")
+}
+
+type graphqlRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage    `json:"data"`
+	Errors []GraphQLErrorItem `json:"errors"`
+}
+
+type graphqlRateLimit struct {
+	Cost      int       `json:"cost"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+// graphqlURL returns GraphQL endpoint, which is not under REST API path on GitHub Enterprise Server.
+func (c *DefaultClient) graphqlURL() string {
+	if base, ok := strings.CutSuffix(c.baseURL, "/api/v3"); ok {
+		return base + "/api/graphql"
+	}
+	return c.baseURL + "/graphql"
+}
+
+// GraphQL sends a GraphQL query and decodes its data into out. GraphQL API requires authentication.
+func (c *DefaultClient) GraphQL(ctx context.Context, query string, variables map[string]any, out any) error {
+	reqBytes, err := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	req, err := c.newRequestURL(ctx, http.MethodPost, c.graphqlURL(), bytes.NewReader(reqBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, respBytes, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	var result graphqlResponse
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		for _, item := range result.Errors {
+			if item.Type == "RATE_LIMITED" {
+				rl, _ := c.rateLimit("graphql")
+				return &RateLimitError{Resource: "graphql", ResetAt: rl.resetAt}
+			}
+		}
+		// partial data is still returned, e.g. when some of the looked up objects don't exist
+		if len(result.Data) == 0 || string(result.Data) == "null" {
+			return &GraphQLError{Errors: result.Errors}
+		}
+		c.logger.Debug("graphql query returned partial data", "err", &GraphQLError{Errors: result.Errors})
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return fmt.Errorf("failed to unmarshal graphql data: %w", err)
+	}
+
+	return nil
+}
+
+type GraphQLRepository struct {
+	NameWithOwner   string `json:"nameWithOwner"`
+	URL             string `json:"url"`
+	Description     string `json:"description"`
+	IsFork          bool   `json:"isFork"`
+	IsPrivate       bool   `json:"isPrivate"`
+	StargazerCount  int    `json:"stargazerCount"`
+	PrimaryLanguage *struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+}
+
+type GraphQLUser struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatarUrl"`
+}
+
+type PullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+}
+
+type GraphQLCommit struct {
+	OID       string `json:"oid"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Signature *struct {
+		IsValid bool   `json:"isValid"`
+		State   string `json:"state"` // e.g. "VALID", "UNSIGNED", "UNKNOWN_KEY"
+	} `json:"signature"`
+	Committer struct {
+		User *GraphQLUser `json:"user"`
+	} `json:"committer"`
+	AssociatedPullRequests struct {
+		Nodes []PullRequest `json:"nodes"`
+	} `json:"associatedPullRequests"`
+}
+
+// CommitLookup is the result of LookupCommits.
+type CommitLookup struct {
+	Repository GraphQLRepository
+	Commits    map[string]GraphQLCommit // by SHA; commits not found are omitted
+	Cost       int                      // rate limit points spent on the query
+	Remaining  int                      // rate limit points remaining after the query
+}
+
+const lookupCommitsQuery = `query($owner: String!, $name: String!) {
+  rateLimit { cost remaining resetAt }
+  repository(owner: $owner, name: $name) {
+    nameWithOwner url description isFork isPrivate stargazerCount
+    primaryLanguage { name }
+%s  }
+}
+
+fragment commitFields on Commit {
+  oid additions deletions
+  signature { isValid state }
+  committer { user { login avatarUrl } }
+  associatedPullRequests(first: 1) { nodes { number title url } }
+}
+`
+
+// LookupCommits looks up repository metadata and up to 50 commits of the repository in a single GraphQL query.
+func (c *DefaultClient) LookupCommits(ctx context.Context, owner, repo string, shas []string) (CommitLookup, error) {
+	if len(shas) > MaxCommitsPerLookup {
+		return CommitLookup{}, fmt.Errorf("maximum %d commits are allowed", MaxCommitsPerLookup)
+	}
+	var fields strings.Builder
+	for i, sha := range shas {
+		// SHAs are inlined into the query; make sure they can't break out of the string literal
+		if _, err := hex.DecodeString(sha); err != nil {
+			return CommitLookup{}, fmt.Errorf("invalid commit sha %q", sha)
+		}
+		fmt.Fprintf(&fields, "    c%d: object(oid: %q) { ...commitFields }\n", i, sha)
+	}
+
+	var data struct {
+		RateLimit  graphqlRateLimit           `json:"rateLimit"`
+		Repository map[string]json.RawMessage `json:"repository"`
+	}
+	vars := map[string]any{"owner": owner, "name": repo}
+	if err := c.GraphQL(ctx, fmt.Sprintf(lookupCommitsQuery, fields.String()), vars, &data); err != nil {
+		return CommitLookup{}, err
+	}
+	c.logger.Debug("graphql rate limit", "cost", data.RateLimit.Cost, "remaining", data.RateLimit.Remaining, "reset_at", data.RateLimit.ResetAt)
+
+	result := CommitLookup{
+		Commits:   make(map[string]GraphQLCommit, len(shas)),
+		Cost:      data.RateLimit.Cost,
+		Remaining: data.RateLimit.Remaining,
+	}
+	if data.Repository == nil {
+		return result, nil
+	}
+	repoBytes, err := json.Marshal(data.Repository)
+	if err != nil {
+		return CommitLookup{}, fmt.Errorf("failed to marshal repository data: %w", err)
+	}
+	if err := json.Unmarshal(repoBytes, &result.Repository); err != nil {
+		return CommitLookup{}, fmt.Errorf("failed to unmarshal repository data: %w", err)
+	}
+	for i := range shas {
+		raw, ok := data.Repository[fmt.Sprintf("c%d", i)]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		var commit GraphQLCommit
+		if err := json.Unmarshal(raw, &commit); err != nil {
+			return CommitLookup{}, fmt.Errorf("failed to unmarshal commit data: %w", err)
+		}
+		result.Commits[commit.OID] = commit
+	}
+
+	return result, nil
+}
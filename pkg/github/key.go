@@ -0,0 +1,66 @@
+package github
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LoadPrivateKey loads GitHub App private key from the file if path is set, or from the value otherwise.
+func LoadPrivateKey(path string, value []byte) (*rsa.PrivateKey, error) {
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key file: %w", err)
+		}
+		value = b
+	}
+	if len(bytes.TrimSpace(value)) == 0 {
+		return nil, errors.New("private key is not set")
+	}
+	return ParsePrivateKey(value)
+}
+
+// ParsePrivateKey parses RSA private key in PKCS#1 or PKCS#8 PEM format, as downloaded from GitHub App settings.
+// Base64-encoded PEM and PEM with escaped newlines ("\n"), which are easier to put in env vars, are accepted as well.
+func ParsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	b = bytes.TrimSpace(b)
+	if !bytes.HasPrefix(b, []byte("-----BEGIN")) {
+		decoded, err := base64.StdEncoding.DecodeString(string(b))
+		if err != nil {
+			return nil, errors.New("private key is neither PEM nor base64-encoded PEM")
+		}
+		b = bytes.TrimSpace(decoded)
+	}
+	b = bytes.ReplaceAll(b, []byte(`\n`), []byte("\n"))
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block of private key")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY": // PKCS#1
+		pk, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PKCS#1 private key: %w", err)
+		}
+		return pk, nil
+	case "PRIVATE KEY": // PKCS#8
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PKCS#8 private key: %w", err)
+		}
+		pk, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is %T, GitHub App keys must be RSA", key)
+		}
+		return pk, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q; expected RSA PRIVATE KEY or PRIVATE KEY", block.Type)
+	}
+}
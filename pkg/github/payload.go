@@ -7,13 +7,32 @@ type accessTokenResponse struct {
 	ExpiresAt time.Time `json:"expires_at"`
 }
 
+type Installation struct {
+	ID                  int64             `json:"id"`
+	AppID               int64             `json:"app_id"`
+	Account             AuthorMeta        `json:"account"`
+	TargetType          string            `json:"target_type"`          // "User" or "Organization"
+	RepositorySelection string            `json:"repository_selection"` // "all" or "selected"
+	Permissions         map[string]string `json:"permissions"`          // e.g. "contents": "read"
+	SuspendedAt         *time.Time        `json:"suspended_at"`
+}
+
 type Author struct {
-	Date time.Time `json:"date"`
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+type Verification struct {
+	Verified bool   `json:"verified"`
+	Reason   string `json:"reason"` // e.g. "valid", "unsigned", "unknown_key"
 }
 
 type Commit struct {
-	Author  Author `json:"author"`
-	Message string `json:"message"`
+	Author       Author       `json:"author"`
+	Committer    Author       `json:"committer"`
+	Message      string       `json:"message"`
+	Verification Verification `json:"verification"`
 }
 
 type AuthorMeta struct {
@@ -21,11 +40,29 @@ type AuthorMeta struct {
 	AvatarURL string `json:"avatar_url"`
 }
 
+type Repository struct {
+	Name        string     `json:"name"`
+	FullName    string     `json:"full_name"`
+	Owner       AuthorMeta `json:"owner"`
+	Private     bool       `json:"private"`
+	Fork        bool       `json:"fork"`
+	HTMLURL     string     `json:"html_url"`
+	Description string     `json:"description"`
+	Language    string     `json:"language"` // not included in search results
+}
+
+type CommitRef struct {
+	SHA string `json:"sha"`
+}
+
 type SearchResultItem struct {
-	SHA        string     `json:"sha"`
-	HTMLURL    string     `json:"html_url"`
-	Commit     Commit     `json:"commit"`
-	AuthorMeta AuthorMeta `json:"author"`
+	SHA           string      `json:"sha"`
+	HTMLURL       string      `json:"html_url"`
+	Commit        Commit      `json:"commit"`
+	AuthorMeta    AuthorMeta  `json:"author"`
+	CommitterMeta AuthorMeta  `json:"committer"`
+	Parents       []CommitRef `json:"parents"`
+	Repository    Repository  `json:"repository"`
 }
 
 type SearchResult struct {
@@ -33,3 +70,27 @@ type SearchResult struct {
 	IncompleteResults bool               `json:"incomplete_results"`
 	Items             []SearchResultItem `json:"items"`
 }
+
+type CommitStats struct {
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+	Total     int `json:"total"`
+}
+
+type CommitFile struct {
+	Filename  string `json:"filename"`
+	Status    string `json:"status"` // "added", "removed", "modified", "renamed", ...
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Changes   int    `json:"changes"`
+}
+
+type CommitDetail struct {
+	SHA           string       `json:"sha"`
+	HTMLURL       string       `json:"html_url"`
+	Commit        Commit       `json:"commit"`
+	AuthorMeta    AuthorMeta   `json:"author"`
+	CommitterMeta AuthorMeta   `json:"committer"`
+	Stats         CommitStats  `json:"stats"`
+	Files         []CommitFile `json:"files"`
+}
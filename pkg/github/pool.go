@@ -0,0 +1,197 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pkglog "sb-scanner/pkg/logger"
+)
+
+// PoolMember is a credential in a client pool.
+type PoolMember struct {
+	Name   string // for logging, e.g. "installation:1234"
+	Client *DefaultClient
+}
+
+// PooledClient spreads requests across several credentials, each with its own rate limit quota.
+// Requests go to the credential with the most remaining quota; exhausted ones are skipped until they reset.
+// Members should be created with WithRateLimitMaxRetries(0) so that they fail fast when rate limited instead of
+// waiting; the pool only waits once every member is exhausted.
+type PooledClient struct {
+	logger  *slog.Logger
+	members []PoolMember
+
+	maxRetries int
+	maxWait    time.Duration
+
+	next atomic.Uint64 // rotates members with equal quota
+
+	mu      sync.Mutex
+	blocked []map[string]time.Time // per member, resource -> time until which it is rate limited, e.g. by secondary limits
+}
+
+// NewPooledClient creates a pool of the members. Once every member is rate limited, requests wait for the earliest
+// reset and are retried, up to maxRetries times and as long as the reset is within maxWait.
+func NewPooledClient(maxRetries int, maxWait time.Duration, members ...PoolMember) (*PooledClient, error) {
+	if len(members) == 0 {
+		return nil, errors.New("client pool needs at least one member")
+	}
+	blocked := make([]map[string]time.Time, len(members))
+	for i := range blocked {
+		blocked[i] = make(map[string]time.Time)
+	}
+	return &PooledClient{
+		logger:     pkglog.GetLogger().With("pkg", "github"),
+		members:    members,
+		maxRetries: maxRetries,
+		maxWait:    maxWait,
+		blocked:    blocked,
+	}, nil
+}
+
+func (p *PooledClient) SearchCommits(ctx context.Context, query Query, opts ...SearchOption) (SearchResult, error) {
+	return poolDo(ctx, p, "search", func(c *DefaultClient) (SearchResult, error) {
+		return c.SearchCommits(ctx, query, opts...)
+	})
+}
+
+func (p *PooledClient) GetCommit(ctx context.Context, owner, repo, sha string) (CommitDetail, error) {
+	return poolDo(ctx, p, "core", func(c *DefaultClient) (CommitDetail, error) {
+		return c.GetCommit(ctx, owner, repo, sha)
+	})
+}
+
+func (p *PooledClient) GetRepository(ctx context.Context, owner, repo string) (Repository, error) {
+	return poolDo(ctx, p, "core", func(c *DefaultClient) (Repository, error) {
+		return c.GetRepository(ctx, owner, repo)
+	})
+}
+
+func (p *PooledClient) LookupCommits(ctx context.Context, owner, repo string, shas []string) (CommitLookup, error) {
+	return poolDo(ctx, p, "graphql", func(c *DefaultClient) (CommitLookup, error) {
+		return c.LookupCommits(ctx, owner, repo, shas)
+	})
+}
+
+// poolDo calls fn with the best member for the resource, moving on to the next one while members are rate limited.
+// Once every member is exhausted, it waits for the earliest reset and tries again.
+func poolDo[T any](ctx context.Context, p *PooledClient, resource string, fn func(*DefaultClient) (T, error)) (T, error) {
+	var zero T
+	for attempt := 0; ; attempt++ {
+		tried := make([]bool, len(p.members))
+		var lastErr error
+		for range p.members {
+			i, resetAt := p.pick(resource, tried)
+			if !resetAt.IsZero() {
+				// the best member left is exhausted, and so are the others
+				if lastErr == nil {
+					lastErr = &RateLimitError{Resource: resource, ResetAt: resetAt}
+				}
+				break
+			}
+			tried[i] = true
+			m := p.members[i]
+
+			result, err := fn(m.Client)
+			if err == nil || !errors.Is(err, ErrRateLimited) {
+				return result, err
+			}
+			var rlErr *RateLimitError
+			if errors.As(err, &rlErr) {
+				p.block(i, resource, rlErr.ResetAt)
+			}
+			p.logger.Warn("pool member is rate limited, trying next", "member", m.Name, "resource", resource, "err", err)
+			lastErr = err
+		}
+
+		wait := time.Until(p.earliestReset(resource)) + time.Second
+		if attempt >= p.maxRetries || wait > p.maxWait {
+			return zero, fmt.Errorf("all %d pool members are rate limited: %w", len(p.members), lastErr)
+		}
+		p.logger.Warn("all pool members are rate limited; waiting for the earliest reset", "resource", resource, "wait", wait.String(), "attempt", attempt+1)
+		if err := sleepContext(ctx, wait); err != nil {
+			return zero, err
+		}
+	}
+}
+
+// block marks the member rate limited on the resource until the given time. Unlike primary limits, which members
+// track from response headers, secondary limits are only known from the error.
+func (p *PooledClient) block(i int, resource string, until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until.After(p.blocked[i][resource]) {
+		p.blocked[i][resource] = until
+	}
+}
+
+// quota returns the remaining quota of the member for the resource and, if it is exhausted, when it resets.
+// Members without known quota are assumed to have plenty.
+func (p *PooledClient) quota(i int, resource string, now time.Time) (int, time.Time) {
+	p.mu.Lock()
+	blockedUntil := p.blocked[i][resource]
+	p.mu.Unlock()
+	remaining := math.MaxInt
+	var resetAt time.Time
+	if rl, ok := p.members[i].Client.rateLimit(resource); ok && rl.resetAt.After(now) {
+		remaining = rl.remaining
+		if remaining == 0 {
+			resetAt = rl.resetAt
+		}
+	}
+	if blockedUntil.After(now) {
+		remaining = 0
+		if blockedUntil.After(resetAt) {
+			resetAt = blockedUntil
+		}
+	}
+	return remaining, resetAt
+}
+
+// pick selects the untried member with the most remaining quota for the resource.
+// Ties are rotated so that requests spread evenly. If every untried member is exhausted, the one resetting first is
+// picked and its reset time returned; otherwise the returned time is zero.
+func (p *PooledClient) pick(resource string, tried []bool) (int, time.Time) {
+	now := time.Now()
+	offset := int(p.next.Add(1))
+	best, bestRemaining := -1, -1
+	var bestReset time.Time
+	for j := range p.members {
+		i := (offset + j) % len(p.members)
+		if tried[i] {
+			continue
+		}
+		remaining, resetAt := p.quota(i, resource, now)
+		switch {
+		case best == -1, remaining > bestRemaining:
+		case remaining == 0 && bestRemaining == 0 && resetAt.Before(bestReset):
+		default:
+			continue
+		}
+		best, bestRemaining, bestReset = i, remaining, resetAt
+	}
+	return best, bestReset
+}
+
+// earliestReset returns the earliest time any member is expected to have quota for the resource again.
+// It is in the past if some member isn't known to be exhausted.
+func (p *PooledClient) earliestReset(resource string) time.Time {
+	now := time.Now()
+	var earliest time.Time
+	for i := range p.members {
+		_, resetAt := p.quota(i, resource, now)
+		if resetAt.IsZero() {
+			return now
+		}
+		if earliest.IsZero() || resetAt.Before(earliest) {
+			earliest = resetAt
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,98 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// GitHub search allows at most 5 AND/OR/NOT operators per query
+	maxQueryOperators = 5
+	// GitHub search allows at most 256 characters per query, not including qualifiers
+	maxQueryLength = 256
+)
+
+// Query is a commit search query.
+// Commits match if their message contains any of the terms, none of the excluded terms, and all qualifiers.
+type Query struct {
+	Terms      []string // phrases with spaces are searched as exact phrases
+	Exclude    []string
+	Qualifiers []string // e.g. "repo:owner/name", "org:name", "is:public", "merge:false"
+}
+
+// String formats the query in GitHub search syntax.
+func (q Query) String() string {
+	var parts []string
+	if terms := q.terms(); terms != "" {
+		parts = append(parts, terms)
+	}
+	parts = append(parts, q.Qualifiers...)
+	return strings.Join(parts, " ")
+}
+
+// terms formats the part of the query that counts towards length and operator limits.
+func (q Query) terms() string {
+	quoted := make([]string, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		quoted = append(quoted, quoteTerm(t))
+	}
+	s := strings.Join(quoted, " OR ")
+	for _, t := range q.Exclude {
+		s += " -" + quoteTerm(t)
+	}
+	return strings.TrimSpace(s)
+}
+
+func (q Query) operators() int {
+	return max(len(q.Terms)-1, 0) + len(q.Exclude)
+}
+
+// Validate checks the query against GitHub search limits.
+func (q Query) Validate() error {
+	if len(q.Terms) == 0 {
+		return fmt.Errorf("query has no search terms")
+	}
+	if n := q.operators(); n > maxQueryOperators {
+		return fmt.Errorf("query has %d operators, maximum %d are allowed", n, maxQueryOperators)
+	}
+	if n := utf8.RuneCountInString(q.terms()); n > maxQueryLength {
+		return fmt.Errorf("query is %d characters long, maximum %d are allowed", n, maxQueryLength)
+	}
+	return nil
+}
+
+// Split splits terms of the query into as few queries as possible that are within GitHub search limits.
+// Excluded terms and qualifiers are repeated in every query.
+func (q Query) Split() ([]Query, error) {
+	var queries []Query
+	cur := Query{Exclude: q.Exclude, Qualifiers: q.Qualifiers}
+	for _, t := range q.Terms {
+		next := cur
+		next.Terms = append(cur.Terms[:len(cur.Terms):len(cur.Terms)], t)
+		if next.Validate() == nil {
+			cur = next
+			continue
+		}
+		if len(cur.Terms) == 0 {
+			// a single term doesn't fit; no split can help
+			return nil, fmt.Errorf("term %q can't fit in a query: %w", t, next.Validate())
+		}
+		queries = append(queries, cur)
+		cur = Query{Exclude: q.Exclude, Qualifiers: q.Qualifiers, Terms: []string{t}}
+		if err := cur.Validate(); err != nil {
+			return nil, fmt.Errorf("term %q can't fit in a query: %w", t, err)
+		}
+	}
+	if len(cur.Terms) > 0 {
+		queries = append(queries, cur)
+	}
+	return queries, nil
+}
+
+func quoteTerm(t string) string {
+	if strings.ContainsAny(t, " \t\"") {
+		return `"` + strings.ReplaceAll(t, `"`, "") + `"`
+	}
+	return t
+}
@@ -0,0 +1,134 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRateLimited is matched (via errors.Is) by every *RateLimitError.
+var ErrRateLimited = errors.New("github api rate limit exceeded")
+
+// RateLimitError is returned when the client gives up waiting for a rate limit to reset.
+type RateLimitError struct {
+	Resource  string    // rate limit resource (e.g. "search", "core")
+	Secondary bool      // whether the secondary (abuse) rate limit was hit
+	ResetAt   time.Time // time when the client is expected to be allowed to send requests again
+}
+
+func (e *RateLimitError) Error() string {
+	kind := "primary"
+	if e.Secondary {
+		kind = "secondary"
+	}
+	return fmt.Sprintf("%s: %s limit on %q resource, resets at %s", ErrRateLimited, kind, e.Resource, e.ResetAt.Format(time.RFC3339))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+const (
+	defaultRateLimitMaxRetries = 3
+	defaultRateLimitMaxWait    = 5 * time.Minute
+
+	// GitHub recommends waiting at least a minute on secondary rate limits without retry-after header
+	secondaryRateLimitBaseWait = time.Minute
+)
+
+// rateLimit is the last known primary rate limit state of a resource.
+type rateLimit struct {
+	limit     int
+	remaining int
+	resetAt   time.Time
+}
+
+// parseRateLimit reads primary rate limit state from response headers.
+func parseRateLimit(h http.Header) (rateLimit, bool) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return rateLimit{}, false
+	}
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return rateLimit{}, false
+	}
+	limit, _ := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	return rateLimit{
+		limit:     limit,
+		remaining: remaining,
+		resetAt:   time.Unix(reset, 0),
+	}, true
+}
+
+// parseRetryAfter reads retry-after header, which is given in seconds by GitHub.
+func parseRetryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+// resourceForPath guesses rate limit resource of the request before the response tells us.
+// Path may have a prefix when using GitHub Enterprise Server (e.g. "/api/v3/search/commits").
+func resourceForPath(path string) string {
+	switch {
+	case strings.Contains(path, "/search/"):
+		return "search"
+	case strings.HasSuffix(path, "/graphql"):
+		return "graphql"
+	default:
+		return "core"
+	}
+}
+
+// checkRateLimited inspects a response and reports how long to wait before retrying if it was rate limited.
+func checkRateLimited(resp *http.Response, body []byte, resource string, attempt int) (time.Duration, *RateLimitError) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, nil
+	}
+
+	if wait, ok := parseRetryAfter(resp.Header); ok {
+		return wait, &RateLimitError{Resource: resource, Secondary: true, ResetAt: time.Now().Add(wait)}
+	}
+	if rl, ok := parseRateLimit(resp.Header); ok && rl.remaining == 0 {
+		// small jitter so that concurrent clients don't stampede right at the reset
+		wait := time.Until(rl.resetAt) + time.Second + rand.N(time.Second)
+		return wait, &RateLimitError{Resource: resource, ResetAt: rl.resetAt}
+	}
+	if bytes.Contains(bytes.ToLower(body), []byte("secondary rate limit")) {
+		backoff := secondaryRateLimitBaseWait << attempt
+		wait := backoff + rand.N(backoff/2)
+		return wait, &RateLimitError{Resource: resource, Secondary: true, ResetAt: time.Now().Add(wait)}
+	}
+
+	return 0, nil
+}
+
+// sleepContext sleeps for given duration or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
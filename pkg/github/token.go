@@ -0,0 +1,222 @@
+package github
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// token is refreshed in the background once it is closer to expiry than this
+	tokenRefreshAhead = 10 * time.Minute
+	// callers block on refresh once the token is closer to expiry than this
+	tokenMinValidity = time.Minute
+)
+
+// InstallationTokenSource issues GitHub App installation access tokens.
+// It is safe for concurrent use; all callers share one token and concurrent refreshes are collapsed into one request.
+type InstallationTokenSource struct {
+	cli *DefaultClient // unauthenticated; token requests are signed with app JWT instead
+
+	appID          string
+	installationID string
+	key            *rsa.PrivateKey
+
+	mu        sync.RWMutex
+	token     string
+	expiresAt time.Time
+
+	group singleflight.Group
+}
+
+// Creates installation token source using GitHub App credentials. No token is issued until first use.
+func NewInstallationTokenSource(appID, installationID string, pk *rsa.PrivateKey, opts ...ClientOption) (*InstallationTokenSource, error) {
+	if appID == "" || installationID == "" {
+		return nil, errors.New("github app id and installation id must be set")
+	}
+	return &InstallationTokenSource{
+		cli:            NewDefaultClient(opts...),
+		appID:          appID,
+		installationID: installationID,
+		key:            pk,
+	}, nil
+}
+
+// Token returns a valid installation access token, refreshing it if needed.
+func (s *InstallationTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.RLock()
+	token, expiresAt := s.token, s.expiresAt
+	s.mu.RUnlock()
+
+	remaining := time.Until(expiresAt)
+	if remaining > tokenRefreshAhead {
+		return token, nil
+	}
+	if remaining > tokenMinValidity {
+		// still usable; refresh without making the caller wait
+		go s.refresh(context.Background())
+		return token, nil
+	}
+	return s.refresh(ctx)
+}
+
+func (s *InstallationTokenSource) refresh(ctx context.Context) (string, error) {
+	// the shared refresh must not be cancelled by whichever caller happened to start it
+	ch := s.group.DoChan("token", func() (any, error) {
+		return s.fetch(context.WithoutCancel(ctx))
+	})
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return "", r.Err
+		}
+		return r.Val.(string), nil
+	}
+}
+
+// appJWT signs a short-lived JWT authenticating as the GitHub App itself.
+func (s *InstallationTokenSource) appJWT() (string, error) {
+	now := time.Now().UTC()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		Issuer:    s.appID,
+		IssuedAt:  jwt.NewNumericDate(now.Add(-1 * time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(10 * time.Minute)),
+	})
+	signedJWT, err := token.SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return signedJWT, nil
+}
+
+// Validate checks that the app credentials are accepted and the installation is usable,
+// with errors explaining which part of the configuration is wrong.
+// Missing permissions in requiredPermissions (e.g. "contents": "read") are reported as errors as well.
+func (s *InstallationTokenSource) Validate(ctx context.Context, requiredPermissions map[string]string) (Installation, error) {
+	signedJWT, err := s.appJWT()
+	if err != nil {
+		return Installation{}, err
+	}
+	req, err := s.cli.newRequest(ctx, http.MethodGet, fmt.Sprintf("/app/installations/%s", s.installationID))
+	if err != nil {
+		return Installation{}, err
+	}
+	req.Header.Add("Authorization", "Bearer "+signedJWT)
+
+	_, respBytes, err := s.cli.do(req)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			switch apiErr.StatusCode {
+			case http.StatusUnauthorized:
+				return Installation{}, fmt.Errorf("github rejected app JWT; check that app id %s matches the private key and the key has not been revoked: %w", s.appID, err)
+			case http.StatusNotFound:
+				return Installation{}, fmt.Errorf("installation %s not found for app %s; check installation id and that the app is installed: %w", s.installationID, s.appID, err)
+			}
+		}
+		return Installation{}, fmt.Errorf("failed to get installation: %w", err)
+	}
+	var inst Installation
+	if err := json.Unmarshal(respBytes, &inst); err != nil {
+		return Installation{}, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	if inst.SuspendedAt != nil {
+		return inst, fmt.Errorf("installation %s on %s was suspended at %s", s.installationID, inst.Account.Login, inst.SuspendedAt.Format(time.RFC3339))
+	}
+	var missing []string
+	for perm, level := range requiredPermissions {
+		granted, ok := inst.Permissions[perm]
+		if !ok || (level == "write" && granted != "write") {
+			missing = append(missing, fmt.Sprintf("%s:%s (granted: %q)", perm, level, granted))
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return inst, fmt.Errorf("installation %s on %s is missing permissions: %s", s.installationID, inst.Account.Login, strings.Join(missing, ", "))
+	}
+
+	return inst, nil
+}
+
+func (s *InstallationTokenSource) fetch(ctx context.Context) (string, error) {
+	signedJWT, err := s.appJWT()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := s.cli.newRequest(ctx, http.MethodPost, fmt.Sprintf("/app/installations/%s/access_tokens", s.installationID))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Authorization", "Bearer "+signedJWT)
+
+	_, respBytes, err := s.cli.do(req)
+	if err != nil {
+		s.cli.logger.Error("failed to refresh github installation access token", "err", err)
+		return "", fmt.Errorf("failed to refresh github auth token: %w", err)
+	}
+	var result accessTokenResponse
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	s.mu.Lock()
+	s.token = result.Token
+	s.expiresAt = result.ExpiresAt
+	s.mu.Unlock()
+	s.cli.logger.Debug("refreshed github installation access token", "expires_at", result.ExpiresAt)
+
+	return result.Token, nil
+}
+
+// TokenSource supplies tokens for authenticating API requests.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource that always returns the same token, e.g. a personal access token.
+type StaticToken string
+
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// Transport is an http.RoundTripper that authenticates requests with tokens from the source.
+type Transport struct {
+	Source TokenSource
+	Base   http.RoundTripper // http.DefaultTransport if nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Header.Get("Authorization") != "" {
+		return base.RoundTrip(req)
+	}
+
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	authReq := req.Clone(req.Context()) // RoundTripper must not modify the original request
+	authReq.Header.Set("Authorization", "Bearer "+token)
+	return base.RoundTrip(authReq)
+}
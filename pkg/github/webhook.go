@@ -0,0 +1,54 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// VerifyWebhookSignature checks X-Hub-Signature-256 header value against HMAC-SHA256 of the payload with the webhook secret.
+func VerifyWebhookSignature(payload []byte, signature, secret string) error {
+	hexSig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return ErrInvalidSignature
+	}
+	sig, err := hex.DecodeString(hexSig)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type PushAuthor struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"` // empty if the email is not linked to a GitHub account
+}
+
+type PushCommit struct {
+	ID        string     `json:"id"`
+	Message   string     `json:"message"`
+	Timestamp time.Time  `json:"timestamp"`
+	URL       string     `json:"url"`
+	Author    PushAuthor `json:"author"`
+	Committer PushAuthor `json:"committer"`
+	Distinct  bool       `json:"distinct"` // false if the commit has been pushed before
+}
+
+// PushEvent is the payload of `push` webhook event.
+type PushEvent struct {
+	Ref        string       `json:"ref"`
+	Repository Repository   `json:"repository"`
+	Commits    []PushCommit `json:"commits"`
+	Sender     AuthorMeta   `json:"sender"`
+}
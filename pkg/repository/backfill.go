@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"sb-scanner/model"
+)
+
+const collectionBackfillChunks = "backfill_chunks"
+
+// GetBackfillChunks returns recorded chunks with given IDs, keyed by ID.
+func (r *Repository) GetBackfillChunks(ctx context.Context, ids []string) (map[string]model.BackfillChunk, error) {
+	col := r.dbcli.Database(r.database).Collection(collectionBackfillChunks)
+
+	cursor, err := col.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find backfill chunks from db: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	chunks := make(map[string]model.BackfillChunk)
+	for cursor.Next(ctx) {
+		var c model.BackfillChunk
+		if err := cursor.Decode(&c); err != nil {
+			return nil, fmt.Errorf("failed to decode document to go struct: %w", err)
+		}
+		chunks[c.ID] = c
+	}
+	return chunks, cursor.Err()
+}
+
+// PutBackfillChunk records the sync run and status of a chunk.
+func (r *Repository) PutBackfillChunk(ctx context.Context, chunk model.BackfillChunk) error {
+	col := r.dbcli.Database(r.database).Collection(collectionBackfillChunks)
+
+	chunk.UpdatedAt = time.Now()
+	_, err := col.ReplaceOne(ctx, bson.M{"_id": chunk.ID}, chunk, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to put backfill chunk to db: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"sb-scanner/pkg/github"
+)
+
+const collectionHTTPCache = "http_cache"
+
+// HTTPCache is a github.Cache backed by a MongoDB collection, shared by every batch run using the database.
+type HTTPCache struct {
+	col *mongo.Collection
+}
+
+func (r *Repository) HTTPCache() *HTTPCache {
+	return &HTTPCache{col: r.dbcli.Database(r.database).Collection(collectionHTTPCache)}
+}
+
+func (c *HTTPCache) Get(ctx context.Context, key string) (github.CacheEntry, bool, error) {
+	var entry github.CacheEntry
+	err := c.col.FindOne(ctx, bson.M{"_id": key}).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return github.CacheEntry{}, false, nil
+	}
+	if err != nil {
+		return github.CacheEntry{}, false, fmt.Errorf("failed to find cache document from db: %w", err)
+	}
+	return entry, true, nil
+}
+
+func (c *HTTPCache) Set(ctx context.Context, key string, entry github.CacheEntry) error {
+	_, err := c.col.ReplaceOne(ctx, bson.M{"_id": key}, entry, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to put cache document to db: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionLeases = "leases"
+
+// AcquireLease takes the named lease for holder until ttl from now, or extends it if holder already has it.
+// It reports false if another holder has the lease and it hasn't expired.
+func (r *Repository) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	col := r.dbcli.Database(r.database).Collection(collectionLeases)
+
+	now := time.Now()
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"holder": holder},
+			bson.M{"expires_at": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"holder": holder, "expires_at": now.Add(ttl)}}
+	_, err := col.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// lease exists, but is held by someone else
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %q: %w", name, err)
+	}
+	return true, nil
+}
+
+// ReleaseLease gives up the named lease if holder has it, so that others can take it without waiting for expiry.
+func (r *Repository) ReleaseLease(ctx context.Context, name, holder string) error {
+	col := r.dbcli.Database(r.database).Collection(collectionLeases)
+
+	_, err := col.UpdateOne(ctx,
+		bson.M{"_id": name, "holder": holder},
+		bson.M{"$set": bson.M{"expires_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release lease %q: %w", name, err)
+	}
+	return nil
+}
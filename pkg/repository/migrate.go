@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"sb-scanner/model"
+)
+
+const (
+	collectionCommitsMigration = "commits_migration"
+
+	migrationBatchSize = 500
+)
+
+// MigrateCommitIDs rewrites IDs of commits stored with an older ID format to the format of model.NewCommitID.
+// Document IDs can't be updated in place, so commits are copied to a new collection which then replaces the
+// commits collection. Indexes have to be created again with EnsureIndexes afterwards.
+// Commits written while the migration runs are lost, so nothing else should write to the database meanwhile.
+// It returns the number of rewritten commits; the commits collection is left untouched if there are none.
+func (r *Repository) MigrateCommitIDs(ctx context.Context) (int, error) {
+	db := r.dbcli.Database(r.database)
+	col := db.Collection(collectionCommits)
+	tmp := db.Collection(collectionCommitsMigration)
+
+	// leftover of an interrupted migration
+	if err := tmp.Drop(ctx); err != nil {
+		return 0, fmt.Errorf("failed to drop migration collection: %w", err)
+	}
+
+	cursor, err := col.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find commit documents from db: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var migrated, copied int
+	batch := make([]any, 0, migrationBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := tmp.InsertMany(ctx, batch); err != nil {
+			return fmt.Errorf("failed to copy commits to migration collection: %w", err)
+		}
+		copied += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for cursor.Next(ctx) {
+		var c model.Commit
+		if err := cursor.Decode(&c); err != nil {
+			return 0, fmt.Errorf("failed to decode document to go struct: %w", err)
+		}
+		// keep the document as is, including fields unknown to model.Commit
+		var doc bson.D
+		if err := cursor.Decode(&doc); err != nil {
+			return 0, fmt.Errorf("failed to decode document: %w", err)
+		}
+		if _, _, err := model.ParseCommitID(c.ID); err != nil {
+			if c.SHA == "" {
+				return 0, fmt.Errorf("commit %q has no sha", c.ID)
+			}
+			id := model.NewCommitID(c.Time, c.SHA)
+			for i := range doc {
+				if doc[i].Key == "_id" {
+					doc[i].Value = id
+				}
+			}
+			migrated++
+		}
+		batch = append(batch, doc)
+		if len(batch) == migrationBatchSize {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read commit documents from db: %w", err)
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+
+	if migrated == 0 {
+		r.logger.Info("commit ids are up to date", "commits", copied)
+		return 0, tmp.Drop(ctx)
+	}
+	r.logger.Info("copied commits to migration collection", "commits", copied, "migrated", migrated)
+
+	err = r.dbcli.Database("admin").RunCommand(ctx, bson.D{
+		{Key: "renameCollection", Value: r.database + "." + collectionCommitsMigration},
+		{Key: "to", Value: r.database + "." + collectionCommits},
+		{Key: "dropTarget", Value: true},
+	}).Err()
+	if err != nil {
+		return 0, fmt.Errorf("failed to replace commits collection: %w", err)
+	}
+	return migrated, nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"sb-scanner/model"
+)
+
+// CommitQuery selects stored commits. Zero fields don't restrict the selection.
+type CommitQuery struct {
+	Model string    // name of the model the commits were scored with
+	Since time.Time // commits made at or after
+}
+
+// StreamCommits yields commits matching the query from the newest. They are read in pages of batchSize by ID rather
+// than with a single cursor, so that slow consumers don't let the cursor time out, and commits updated meanwhile are
+// neither skipped nor yielded twice.
+func (r *Repository) StreamCommits(ctx context.Context, q CommitQuery, batchSize int64) iter.Seq2[model.Commit, error] {
+	return func(yield func(model.Commit, error) bool) {
+		col := r.dbcli.Database(r.database).Collection(collectionCommits)
+
+		var bookmark string
+		opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(batchSize)
+		for {
+			id := bson.M{}
+			if !q.Since.IsZero() {
+				// IDs start with the commit time, see model.NewCommitID
+				id["$gte"] = model.NewCommitID(q.Since, "")
+			}
+			if bookmark != "" {
+				id["$lt"] = bookmark
+			}
+			filter := bson.M{}
+			if len(id) > 0 {
+				filter["_id"] = id
+			}
+			if q.Model != "" {
+				filter["sentiment.model"] = q.Model
+			}
+
+			cursor, err := col.Find(ctx, filter, opts)
+			if err != nil {
+				yield(model.Commit{}, fmt.Errorf("failed to find commit documents from db: %w", err))
+				return
+			}
+			var commits []model.Commit
+			err = cursor.All(ctx, &commits)
+			if err != nil {
+				yield(model.Commit{}, fmt.Errorf("failed to decode documents to go structs: %w", err))
+				return
+			}
+			for _, c := range commits {
+				if !yield(c, nil) {
+					return
+				}
+			}
+			if int64(len(commits)) < batchSize {
+				return
+			}
+			bookmark = commits[len(commits)-1].ID
+		}
+	}
+}
+
+// UpdateCommitSentiments sets sentiments of stored commits, keyed by commit ID. The sentiment each commit was scored
+// with so far is appended to its sentiment history.
+func (r *Repository) UpdateCommitSentiments(ctx context.Context, sentiments map[string]model.Sentiment) error {
+	col := r.dbcli.Database(r.database).Collection(collectionCommits)
+
+	input := []mongo.WriteModel{}
+	for id, s := range sentiments {
+		// an update pipeline, so that the current sentiment is moved to history by the same write
+		update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"sentiment_history": bson.M{"$concatArrays": bson.A{
+				bson.M{"$ifNull": bson.A{"$sentiment_history", bson.A{}}},
+				bson.A{"$sentiment"},
+			}},
+			"sentiment": bson.M{"$literal": s},
+		}}}}
+		wm := mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id}).
+			SetUpdate(update)
+		input = append(input, wm)
+	}
+	if len(input) == 0 {
+		return nil
+	}
+
+	opts := options.BulkWrite().SetOrdered(false)
+	if _, err := col.BulkWrite(ctx, input, opts); err != nil {
+		return fmt.Errorf("failed to bulk write commit sentiments to db: %w", err)
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -35,14 +37,131 @@ func NewRepository(dbURL, dbName string) (*Repository, error) {
 
 const collectionCommits = "commits"
 
+// EnsureIndexes creates indexes used by queries on stored commits. It is safe to call repeatedly.
+// Unique indexes can't be created while commits stored before deduplication was introduced are left; those are merged
+// by MergeDuplicateCommits.
+func (r *Repository) EnsureIndexes(ctx context.Context) error {
+	col := r.dbcli.Database(r.database).Collection(collectionCommits)
+
+	_, err := col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "repository.full_name", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "repository.fork", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "sha", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{
+			Keys: bson.D{{Key: "fingerprint", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"fingerprint": bson.M{"$type": "string"}}),
+		},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("failed to create commit indexes, run migrate to merge commits stored more than once: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create commit indexes: %w", err)
+	}
+
+	// cached responses are revalidated on use, expiry only keeps the collection from growing forever
+	_, err = r.dbcli.Database(r.database).Collection(collectionHTTPCache).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "stored_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32((30 * 24 * time.Hour).Seconds())),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create http cache indexes: %w", err)
+	}
+
+	return nil
+}
+
+// MergeDuplicateCommits merges commits stored more than once with the same SHA into the one with the lowest ID.
+// Commits without repository list get one with their own repository. It reads the whole collection, and is only needed
+// once for commits stored before deduplication was introduced.
+func (r *Repository) MergeDuplicateCommits(ctx context.Context) error {
+	col := r.dbcli.Database(r.database).Collection(collectionCommits)
+
+	_, err := col.UpdateMany(ctx,
+		bson.M{"repositories": bson.M{"$exists": false}, "repository.full_name": bson.M{"$exists": true}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"repositories": bson.A{"$repository.full_name"}}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to fill in commit repositories: %w", err)
+	}
+
+	cursor, err := col.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":          "$sha",
+			"ids":          bson.M{"$push": "$_id"},
+			"repositories": bson.M{"$addToSet": "$repository.full_name"},
+			"count":        bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate commits: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var dup struct {
+			SHA          string   `bson:"_id"`
+			IDs          []string `bson:"ids"`
+			Repositories []string `bson:"repositories"`
+		}
+		if err := cursor.Decode(&dup); err != nil {
+			return fmt.Errorf("failed to decode duplicate commits: %w", err)
+		}
+		slices.Sort(dup.IDs)
+		_, err := col.UpdateOne(ctx,
+			bson.M{"_id": dup.IDs[0]},
+			bson.M{"$addToSet": bson.M{"repositories": bson.M{"$each": dup.Repositories}}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to merge duplicate commits: %w", err)
+		}
+		if _, err := col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": dup.IDs[1:]}}); err != nil {
+			return fmt.Errorf("failed to delete duplicate commits: %w", err)
+		}
+		r.logger.Info("merged duplicate commits", "commit_sha", dup.SHA, "commit_id", dup.IDs[0], "merged", len(dup.IDs)-1)
+	}
+	return cursor.Err()
+}
+
+// PutCommits stores commits, deduplicated by SHA and fingerprint across repositories.
+// A commit that is already stored is not replaced; its repositories are added to the stored one
+// and its sentiment and details are updated.
 func (r *Repository) PutCommits(ctx context.Context, commits []model.Commit) error {
 	col := r.dbcli.Database(r.database).Collection(collectionCommits)
 
 	input := []mongo.WriteModel{}
 	for _, commit := range commits {
+		filter := bson.M{"sha": commit.SHA}
+		insert := bson.M{
+			"_id":     commit.ID,
+			"source":  commit.Source,
+			"sha":     commit.SHA,
+			"url":     commit.URL,
+			"message": commit.Message,
+			"author":  commit.Author,
+			"time":    commit.Time,
+		}
+		if commit.Fingerprint != "" {
+			filter = bson.M{"$or": bson.A{filter, bson.M{"fingerprint": commit.Fingerprint}}}
+			insert["fingerprint"] = commit.Fingerprint
+		}
+		if commit.Repository != nil {
+			insert["repository"] = commit.Repository
+		}
+		set := bson.M{"sentiment": commit.Sentiment}
+		if commit.Details != nil {
+			set["details"] = commit.Details
+		}
+		update := bson.M{"$setOnInsert": insert, "$set": set}
+		if len(commit.Repositories) > 0 {
+			update["$addToSet"] = bson.M{"repositories": bson.M{"$each": commit.Repositories}}
+		}
+
 		wm := mongo.NewUpdateOneModel().
-			SetFilter(bson.M{"_id": commit.ID}).
-			SetUpdate(bson.M{"$set": commit}).
+			SetFilter(filter).
+			SetUpdate(update).
 			SetUpsert(true)
 		input = append(input, wm)
 	}
@@ -56,6 +175,8 @@ func (r *Repository) PutCommits(ctx context.Context, commits []model.Commit) err
 	return nil
 }
 
+// GetCommits returns commits from the newest, starting after the bookmark, which is the ID of the last commit of the
+// previous page. IDs sort by commit time, so paging by ID is paging by time.
 func (r *Repository) GetCommits(ctx context.Context, bookmark *string, limit int64) ([]model.Commit, error) {
 	col := r.dbcli.Database(r.database).Collection(collectionCommits)
 
@@ -81,3 +202,55 @@ func (r *Repository) GetCommits(ctx context.Context, bookmark *string, limit int
 
 	return commits, nil
 }
+
+// FindCommitIDs looks up stored commits by SHA and fingerprint.
+// It returns IDs of the stored commits keyed by whichever of the given SHAs and fingerprints matched them.
+func (r *Repository) FindCommitIDs(ctx context.Context, shas, fingerprints []string) (map[string]string, error) {
+	col := r.dbcli.Database(r.database).Collection(collectionCommits)
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"sha": bson.M{"$in": shas}},
+		bson.M{"fingerprint": bson.M{"$in": fingerprints}},
+	}}
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "sha": 1, "fingerprint": 1})
+	cursor, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find commit documents from db: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	ids := make(map[string]string)
+	for cursor.Next(ctx) {
+		var c model.Commit
+		if err := cursor.Decode(&c); err != nil {
+			return nil, fmt.Errorf("failed to decode document to go struct: %w", err)
+		}
+		ids[c.SHA] = c.ID
+		if c.Fingerprint != "" {
+			ids[c.Fingerprint] = c.ID
+		}
+	}
+	return ids, cursor.Err()
+}
+
+// LinkCommitRepositories adds repositories to the repository lists of stored commits, keyed by commit ID.
+func (r *Repository) LinkCommitRepositories(ctx context.Context, links map[string][]string) error {
+	col := r.dbcli.Database(r.database).Collection(collectionCommits)
+
+	input := []mongo.WriteModel{}
+	for id, repositories := range links {
+		wm := mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id}).
+			SetUpdate(bson.M{"$addToSet": bson.M{"repositories": bson.M{"$each": repositories}}})
+		input = append(input, wm)
+	}
+	if len(input) == 0 {
+		return nil
+	}
+
+	opts := options.BulkWrite().SetOrdered(false)
+	if _, err := col.BulkWrite(ctx, input, opts); err != nil {
+		return fmt.Errorf("failed to bulk write commit repositories to db: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"sb-scanner/model"
+)
+
+const collectionSyncRuns = "sync_runs"
+
+// ErrSyncRunNotFound is returned when there is no sync run with given ID.
+var ErrSyncRunNotFound = errors.New("sync run not found")
+
+// CreateSyncRun stores a new running sync run, setting its ID and start time.
+func (r *Repository) CreateSyncRun(ctx context.Context, run *model.SyncRun) error {
+	col := r.dbcli.Database(r.database).Collection(collectionSyncRuns)
+
+	now := time.Now()
+	run.ID = primitive.NewObjectID().Hex()
+	run.Status = model.SyncRunRunning
+	run.StartedAt = now
+	run.UpdatedAt = now
+	if run.Evaluated == nil {
+		run.Evaluated = []string{}
+	}
+	if _, err := col.InsertOne(ctx, run); err != nil {
+		return fmt.Errorf("failed to insert sync run to db: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetSyncRun(ctx context.Context, id string) (model.SyncRun, error) {
+	col := r.dbcli.Database(r.database).Collection(collectionSyncRuns)
+
+	var run model.SyncRun
+	err := col.FindOne(ctx, bson.M{"_id": id}).Decode(&run)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.SyncRun{}, fmt.Errorf("%w: %s", ErrSyncRunNotFound, id)
+	}
+	if err != nil {
+		return model.SyncRun{}, fmt.Errorf("failed to find sync run from db: %w", err)
+	}
+	return run, nil
+}
+
+// SaveSyncCheckpoint moves the checkpoint of a sync run and adds keys of commits evaluated since the last save.
+func (r *Repository) SaveSyncCheckpoint(ctx context.Context, id string, checkpoint model.SyncCheckpoint, evaluated []string) error {
+	col := r.dbcli.Database(r.database).Collection(collectionSyncRuns)
+
+	update := bson.M{
+		"$set": bson.M{"checkpoint": checkpoint, "updated_at": time.Now()},
+	}
+	if len(evaluated) > 0 {
+		update["$addToSet"] = bson.M{"evaluated": bson.M{"$each": evaluated}}
+	}
+	if _, err := col.UpdateOne(ctx, bson.M{"_id": id}, update); err != nil {
+		return fmt.Errorf("failed to update sync run checkpoint: %w", err)
+	}
+	return nil
+}
+
+// SetSyncRunStatus updates status of a sync run; errMsg is cleared unless the run failed.
+func (r *Repository) SetSyncRunStatus(ctx context.Context, id string, status model.SyncRunStatus, errMsg string) error {
+	col := r.dbcli.Database(r.database).Collection(collectionSyncRuns)
+
+	update := bson.M{
+		"$set": bson.M{"status": status, "updated_at": time.Now(), "error": errMsg},
+	}
+	if _, err := col.UpdateOne(ctx, bson.M{"_id": id}, update); err != nil {
+		return fmt.Errorf("failed to update sync run status: %w", err)
+	}
+	return nil
+}
+
+// ResumeSyncRun marks a sync run running again, clearing its error and counting the attempt.
+func (r *Repository) ResumeSyncRun(ctx context.Context, id string) error {
+	col := r.dbcli.Database(r.database).Collection(collectionSyncRuns)
+
+	update := bson.M{
+		"$set": bson.M{"status": model.SyncRunRunning, "updated_at": time.Now(), "error": ""},
+		"$inc": bson.M{"attempts": 1},
+	}
+	if _, err := col.UpdateOne(ctx, bson.M{"_id": id}, update); err != nil {
+		return fmt.Errorf("failed to update sync run status: %w", err)
+	}
+	return nil
+}
+
+// TouchSyncRun updates the update time of a sync run, to tell that it is still running.
+func (r *Repository) TouchSyncRun(ctx context.Context, id string) error {
+	col := r.dbcli.Database(r.database).Collection(collectionSyncRuns)
+
+	if _, err := col.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"updated_at": time.Now()}}); err != nil {
+		return fmt.Errorf("failed to update sync run: %w", err)
+	}
+	return nil
+}
+
+const collectionSyncWatermarks = "sync_watermarks"
+
+// GetSyncWatermarks returns watermarks of keyword groups, keyed by group name.
+func (r *Repository) GetSyncWatermarks(ctx context.Context) (map[string]time.Time, error) {
+	col := r.dbcli.Database(r.database).Collection(collectionSyncWatermarks)
+
+	cursor, err := col.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find sync watermarks from db: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	watermarks := make(map[string]time.Time)
+	for cursor.Next(ctx) {
+		var wm model.SyncWatermark
+		if err := cursor.Decode(&wm); err != nil {
+			return nil, fmt.Errorf("failed to decode document to go struct: %w", err)
+		}
+		watermarks[wm.Group] = wm.Until
+	}
+	return watermarks, cursor.Err()
+}
+
+// AdvanceSyncWatermark moves the watermark of a keyword group to until after the group has been synced from since.
+// The watermark is only moved forward, and only if the window leaves no gap after it, so that syncing an older or
+// a detached window doesn't affect it. It reports whether the watermark was moved.
+func (r *Repository) AdvanceSyncWatermark(ctx context.Context, group string, since, until time.Time) (bool, error) {
+	col := r.dbcli.Database(r.database).Collection(collectionSyncWatermarks)
+
+	var wm model.SyncWatermark
+	err := col.FindOne(ctx, bson.M{"_id": group}).Decode(&wm)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+	case err != nil:
+		return false, fmt.Errorf("failed to find sync watermark from db: %w", err)
+	case !until.After(wm.Until) || since.After(wm.Until):
+		return false, nil
+	}
+
+	wm = model.SyncWatermark{Group: group, Until: until, UpdatedAt: time.Now()}
+	if _, err := col.ReplaceOne(ctx, bson.M{"_id": group}, wm, options.Replace().SetUpsert(true)); err != nil {
+		return false, fmt.Errorf("failed to update sync watermark: %w", err)
+	}
+	return true, nil
+}
+
+// ResetSyncWatermarks deletes watermarks of the keyword groups.
+func (r *Repository) ResetSyncWatermarks(ctx context.Context, groups []string) error {
+	col := r.dbcli.Database(r.database).Collection(collectionSyncWatermarks)
+
+	if _, err := col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": groups}}); err != nil {
+		return fmt.Errorf("failed to delete sync watermarks: %w", err)
+	}
+	return nil
+}
+
+// GetLatestSyncRun returns the most recently started sync run.
+func (r *Repository) GetLatestSyncRun(ctx context.Context) (model.SyncRun, error) {
+	col := r.dbcli.Database(r.database).Collection(collectionSyncRuns)
+
+	var run model.SyncRun
+	opts := options.FindOne().SetSort(bson.D{{Key: "started_at", Value: -1}})
+	err := col.FindOne(ctx, bson.M{}, opts).Decode(&run)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.SyncRun{}, ErrSyncRunNotFound
+	}
+	if err != nil {
+		return model.SyncRun{}, fmt.Errorf("failed to find sync run from db: %w", err)
+	}
+	return run, nil
+}
@@ -0,0 +1,98 @@
+package source
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Filter rule names, reported for every dropped commit.
+const (
+	FilterRuleBot            = "bot"
+	FilterRuleAuthor         = "author"
+	FilterRuleCommitterEmail = "committer_email"
+	FilterRuleMerge          = "merge"
+	FilterRuleMessage        = "message"
+)
+
+// FilterConfig configures which commits are dropped before sentiment evaluation.
+type FilterConfig struct {
+	Bots            bool     `mapstructure:"bots"`             // drop authors whose login ends with "[bot]"
+	Merges          bool     `mapstructure:"merges"`           // drop commits with more than one parent
+	Authors         []string `mapstructure:"authors"`          // author login glob patterns, e.g. "dependabot*"
+	CommitterEmails []string `mapstructure:"committer_emails"` // committer email glob patterns, e.g. "*@renovateapp.com"
+	Messages        []string `mapstructure:"messages"`         // commit message regular expressions
+}
+
+// Filter drops bot and automated commits, which are not worth evaluating.
+type Filter struct {
+	bots            bool
+	merges          bool
+	authors         []string
+	committerEmails []string
+	messages        []*regexp.Regexp
+}
+
+// NewFilter validates patterns of the config. Login and email patterns are matched case-insensitively.
+func NewFilter(cfg FilterConfig) (*Filter, error) {
+	f := &Filter{
+		bots:   cfg.Bots,
+		merges: cfg.Merges,
+	}
+	for _, p := range cfg.Authors {
+		p = strings.ToLower(p)
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid author pattern %q: %w", p, err)
+		}
+		f.authors = append(f.authors, p)
+	}
+	for _, p := range cfg.CommitterEmails {
+		p = strings.ToLower(p)
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid committer email pattern %q: %w", p, err)
+		}
+		f.committerEmails = append(f.committerEmails, p)
+	}
+	for _, expr := range cfg.Messages {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message pattern %q: %w", expr, err)
+		}
+		f.messages = append(f.messages, re)
+	}
+	return f, nil
+}
+
+// Drop reports whether the commit should be dropped, and the name of the first rule that matched it.
+func (f *Filter) Drop(c Commit) (string, bool) {
+	login := strings.ToLower(c.Author.Username)
+	switch {
+	case f.bots && strings.HasSuffix(login, "[bot]"):
+		return FilterRuleBot, true
+	case matchesGlob(login, f.authors):
+		return FilterRuleAuthor, true
+	case matchesGlob(strings.ToLower(c.CommitterEmail), f.committerEmails):
+		return FilterRuleCommitterEmail, true
+	case f.merges && c.Parents > 1:
+		return FilterRuleMerge, true
+	}
+	for _, re := range f.messages {
+		if re.MatchString(c.Message) {
+			return FilterRuleMessage, true
+		}
+	}
+	return "", false
+}
+
+func matchesGlob(s string, patterns []string) bool {
+	if s == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
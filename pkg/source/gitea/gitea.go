@@ -0,0 +1,145 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	pkglog "sb-scanner/pkg/logger"
+)
+
+// Client is a minimal Gitea/Forgejo REST API client.
+type Client struct {
+	logger  *slog.Logger
+	httpcli *http.Client
+
+	baseURL string // e.g. "https://gitea.example.com/api/v1"
+	token   string
+}
+
+// Creates Gitea API client for the instance at baseURL (e.g. "https://gitea.example.com").
+// Token is optional; without it only public repositories are visible.
+func NewClient(baseURL, token string, httpcli *http.Client) *Client {
+	return &Client{
+		logger:  pkglog.GetLogger().With("pkg", "gitea"),
+		httpcli: httpcli,
+		baseURL: strings.TrimSuffix(baseURL, "/") + "/api/v1",
+		token:   token,
+	}
+}
+
+// APIError is returned for non-2xx responses from the Gitea API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gitea api error: %d %s", e.StatusCode, e.Message)
+}
+
+// SearchRepositories lists repositories visible to the client, a page at a time.
+func (c *Client) SearchRepositories(ctx context.Context, page, limit int) ([]Repository, error) {
+	q := url.Values{}
+	q.Add("page", strconv.Itoa(page))
+	q.Add("limit", strconv.Itoa(limit))
+
+	respBytes, err := c.get(ctx, "/repos/search", q)
+	if err != nil {
+		return nil, err
+	}
+	var result searchRepositoriesResponse
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result.Data, nil
+}
+
+// ListOwnerRepositories lists repositories of an organization or, if there is no organization of that name, of a
+// user, a page at a time.
+func (c *Client) ListOwnerRepositories(ctx context.Context, owner string, page, limit int) ([]Repository, error) {
+	q := url.Values{}
+	q.Add("page", strconv.Itoa(page))
+	q.Add("limit", strconv.Itoa(limit))
+
+	respBytes, err := c.get(ctx, fmt.Sprintf("/orgs/%s/repos", url.PathEscape(owner)), q)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		respBytes, err = c.get(ctx, fmt.Sprintf("/users/%s/repos", url.PathEscape(owner)), q)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result []Repository
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListCommits lists commits of the repository's default branch made in [since, until], newest first.
+func (c *Client) ListCommits(ctx context.Context, owner, repo string, since, until time.Time, page, limit int) ([]Commit, error) {
+	q := url.Values{}
+	q.Add("since", since.Format(time.RFC3339))
+	q.Add("until", until.Format(time.RFC3339))
+	q.Add("page", strconv.Itoa(page))
+	q.Add("limit", strconv.Itoa(limit))
+	// skip expensive per-commit data we don't use
+	q.Add("stat", "false")
+	q.Add("verification", "false")
+	q.Add("files", "false")
+
+	respBytes, err := c.get(ctx, fmt.Sprintf("/repos/%s/%s/commits", url.PathEscape(owner), url.PathEscape(repo)), q)
+	if err != nil {
+		return nil, err
+	}
+	var result []Commit
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, q url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.URL.RawQuery = q.Encode()
+	req.Header.Add("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Add("Authorization", "token "+c.token)
+	}
+	c.logger.Debug("gitea request", "path", path, "query", req.URL.RawQuery)
+
+	resp, err := c.httpcli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(respBytes))}
+		var errResp errorResponse
+		if err := json.Unmarshal(respBytes, &errResp); err == nil && errResp.Message != "" {
+			apiErr.Message = errResp.Message
+		}
+		return nil, apiErr
+	}
+
+	return respBytes, nil
+}
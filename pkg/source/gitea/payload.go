@@ -0,0 +1,56 @@
+package gitea
+
+import "time"
+
+type User struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type Repository struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	Owner       User   `json:"owner"`
+	Description string `json:"description"`
+	HTMLURL     string `json:"html_url"`
+	Private     bool   `json:"private"`
+	Internal    bool   `json:"internal"` // visible to signed-in users only
+	Fork        bool   `json:"fork"`
+	Mirror      bool   `json:"mirror"`
+	Empty       bool   `json:"empty"`
+	Language    string `json:"language"`
+}
+
+type searchRepositoriesResponse struct {
+	OK   bool         `json:"ok"`
+	Data []Repository `json:"data"`
+}
+
+type CommitUser struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+type CommitMeta struct {
+	SHA string `json:"sha"`
+}
+
+type RepoCommit struct {
+	Message   string     `json:"message"`
+	Author    CommitUser `json:"author"`
+	Committer CommitUser `json:"committer"`
+}
+
+type Commit struct {
+	SHA     string       `json:"sha"`
+	HTMLURL string       `json:"html_url"`
+	Commit  RepoCommit   `json:"commit"`
+	Author  *User        `json:"author"` // nil if commit author is not a Gitea user
+	Parents []CommitMeta `json:"parents"`
+}
+
+type errorResponse struct {
+	Message string `json:"message"`
+}
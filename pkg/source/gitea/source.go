@@ -0,0 +1,153 @@
+package gitea
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"sb-scanner/model"
+	pkglog "sb-scanner/pkg/logger"
+	"sb-scanner/pkg/source"
+)
+
+// Source scans commit history of Gitea/Forgejo repositories.
+// Gitea has no global commit search, so every commit in the window is listed and matched against keywords here.
+type Source struct {
+	logger *slog.Logger
+	cli    *Client
+
+	owners       []string // only scan repositories of these users/orgs; all visible repositories if empty
+	perPage      int
+	maxPages     int  // per repository
+	allowPrivate bool // whether private and internal repositories are scanned, and so shown on the public feed
+}
+
+func NewSource(cli *Client, owners []string, perPage, maxPages int, allowPrivate bool) *Source {
+	return &Source{
+		logger:       pkglog.GetLogger().With("pkg", "source/gitea"),
+		cli:          cli,
+		owners:       owners,
+		perPage:      perPage,
+		maxPages:     maxPages,
+		allowPrivate: allowPrivate,
+	}
+}
+
+func (s *Source) Name() string {
+	return "gitea"
+}
+
+func (s *Source) Search(ctx context.Context, q source.Query, fn func(source.Page) error) error {
+	if len(s.owners) == 0 {
+		return s.scanRepositories(ctx, q, fn, "", func(page int) ([]Repository, error) {
+			return s.cli.SearchRepositories(ctx, page, s.perPage)
+		})
+	}
+	for _, owner := range s.owners {
+		err := s.scanRepositories(ctx, q, fn, owner, func(page int) ([]Repository, error) {
+			return s.cli.ListOwnerRepositories(ctx, owner, page, s.perPage)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanRepositories scans repositories listed a page at a time by list.
+func (s *Source) scanRepositories(ctx context.Context, q source.Query, fn func(source.Page) error, owner string, list func(page int) ([]Repository, error)) error {
+	repoPage := 1
+	for {
+		repos, err := list(repoPage)
+		if err != nil {
+			s.logger.Error("failed to list repositories", "err", err, "owner", owner, "page", repoPage)
+			return err
+		}
+		for _, r := range repos {
+			if r.Empty || (!s.allowPrivate && (r.Private || r.Internal)) {
+				continue
+			}
+			if err := s.scanRepository(ctx, r, q, fn); err != nil {
+				return err
+			}
+		}
+		if len(repos) < s.perPage {
+			break
+		}
+		repoPage++
+	}
+
+	return nil
+}
+
+func (s *Source) scanRepository(ctx context.Context, r Repository, q source.Query, fn func(source.Page) error) error {
+	repository := &model.Repository{
+		FullName:    r.FullName,
+		Owner:       r.Owner.Login,
+		URL:         r.HTMLURL,
+		Description: r.Description,
+		Fork:        r.Fork,
+		Private:     r.Private || r.Internal,
+		Language:    r.Language,
+	}
+
+	for commitPage := 1; commitPage <= s.maxPages; commitPage++ {
+		commits, err := s.cli.ListCommits(ctx, r.Owner.Login, r.Name, q.Since, q.Until, commitPage, s.perPage)
+		if err != nil {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusConflict || apiErr.StatusCode == http.StatusNotFound) {
+				// empty repository or no default branch
+				s.logger.Info("skipping repository without commits", "repository", r.FullName, "err", err)
+				return nil
+			}
+			s.logger.Error("failed to list commits", "err", err, "repository", r.FullName, "page", commitPage)
+			return err
+		}
+		s.logger.Debug("fetched commits from gitea", "repository", r.FullName, "page", commitPage, "items", len(commits))
+
+		var page source.Page
+		for _, c := range commits {
+			if !q.Group.Matches(c.Commit.Message) {
+				continue
+			}
+			page.Commits = append(page.Commits, toCommit(c, repository))
+		}
+		if len(page.Commits) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+		if len(commits) < s.perPage {
+			break
+		}
+		if commitPage == s.maxPages {
+			s.logger.Warn("reached max_pages with commits still inside the window; older commits are not scanned",
+				"repository", r.FullName, "max_pages", s.maxPages, "since", q.Since)
+		}
+	}
+
+	return nil
+}
+
+func toCommit(c Commit, repository *model.Repository) source.Commit {
+	author := model.Author{Username: c.Commit.Author.Name}
+	if c.Author != nil {
+		author = model.Author{
+			Username:  c.Author.Login,
+			AvatarURL: c.Author.AvatarURL,
+		}
+	}
+	return source.Commit{
+		SHA:            c.SHA,
+		URL:            c.HTMLURL,
+		Message:        c.Commit.Message,
+		Author:         author,
+		AuthorEmail:    c.Commit.Author.Email,
+		CommitterEmail: c.Commit.Committer.Email,
+		Parents:        len(c.Parents),
+		Time:           c.Commit.Author.Date,
+		Repository:     repository,
+	}
+}
@@ -0,0 +1,325 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"sb-scanner/model"
+	githubapi "sb-scanner/pkg/github"
+	pkglog "sb-scanner/pkg/logger"
+	"sb-scanner/pkg/source"
+)
+
+// EnrichMode selects how commit details are fetched for flagged commits.
+type EnrichMode string
+
+const (
+	EnrichNone    EnrichMode = ""
+	EnrichREST    EnrichMode = "rest"    // request per commit; includes changed files
+	EnrichGraphQL EnrichMode = "graphql" // query per repository; includes stars and associated pull request, requires authentication
+)
+
+// searchResultCap is the maximum number of results GitHub search API returns for a single query.
+const searchResultCap = 1000
+
+// Source searches commits with GitHub commit search API.
+type Source struct {
+	logger *slog.Logger
+	cli    githubapi.Client
+
+	perPage  int
+	maxPages int
+	enrich   EnrichMode
+
+	repoLanguages map[string]string // repository full name -> primary language
+	graphqlCost   atomic.Int64      // GraphQL rate limit points used for enrichment, see GraphQLCost
+}
+
+func NewSource(cli githubapi.Client, perPage, maxPages int, enrich EnrichMode) *Source {
+	return &Source{
+		logger:        pkglog.GetLogger().With("pkg", "source/github"),
+		cli:           cli,
+		perPage:       perPage,
+		maxPages:      maxPages,
+		enrich:        enrich,
+		repoLanguages: make(map[string]string),
+	}
+}
+
+func (s *Source) Name() string {
+	return "github"
+}
+
+func (s *Source) Search(ctx context.Context, q source.Query, fn func(source.Page) error) error {
+	// search query has second precision; align window so that splitting doesn't produce sub-second slices
+	stime := q.Since.Truncate(time.Second)
+	etime := q.Until.Truncate(time.Second)
+
+	queries, err := githubapi.Query{
+		Terms:      q.Group.Keywords,
+		Exclude:    q.Group.Exclude,
+		Qualifiers: q.Group.Qualifiers,
+	}.Split()
+	if err != nil {
+		s.logger.Error("failed to build search queries", "err", err, "group", q.Group.Name)
+		return err
+	}
+	for _, query := range queries {
+		if err := s.searchWindow(ctx, query, stime, etime, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// searchWindow searches commits in [stime, etime].
+// If the window has more matches than can be paged through, it is split in half recursively until each slice fits.
+func (s *Source) searchWindow(ctx context.Context, query githubapi.Query, stime, etime time.Time, fn func(source.Page) error) error {
+	maxResults := min(searchResultCap, s.perPage*s.maxPages)
+
+	searchPage := 1
+	for searchPage <= s.maxPages {
+		opts := []githubapi.SearchOption{
+			githubapi.WithStartTime(stime),
+			githubapi.WithEndTime(etime),
+			githubapi.WithSize(s.perPage),
+			githubapi.WithPage(searchPage),
+		}
+		searched, err := s.cli.SearchCommits(ctx, query, opts...)
+		if err != nil {
+			var rlErr *githubapi.RateLimitError
+			if errors.As(err, &rlErr) {
+				s.logger.Error("github rate limit exceeded", "resource", rlErr.Resource, "secondary", rlErr.Secondary, "reset_at", rlErr.ResetAt)
+				return err
+			}
+			var apiErr *githubapi.APIError
+			if errors.As(err, &apiErr) {
+				s.logger.Error("github api returned an error", "status", apiErr.StatusCode, "message", apiErr.Message, "request_id", apiErr.RequestID, "err", err)
+				return err
+			}
+			s.logger.Error("failed to search commits", "err", err)
+			return err
+		}
+		if searchPage == 1 && searched.TotalCount > maxResults {
+			if etime.After(stime) {
+				mid := stime.Add(etime.Sub(stime) / 2).Truncate(time.Second)
+				s.logger.Info("too many results in search window, splitting", "stime", stime, "etime", etime, "total_count", searched.TotalCount, "max_results", maxResults)
+				if err := s.searchWindow(ctx, query, stime, mid, fn); err != nil {
+					return err
+				}
+				return s.searchWindow(ctx, query, mid.Add(time.Second), etime, fn)
+			}
+			s.logger.Warn("too many results in a single second window, some commits will be missed", "stime", stime, "total_count", searched.TotalCount, "max_results", maxResults)
+		}
+		if searched.IncompleteResults {
+			s.logger.Warn("github returned incomplete search results", "page", searchPage, "stime", stime, "etime", etime)
+		}
+		if len(searched.Items) == 0 {
+			s.logger.Info("no more commits found in search window", "stime", stime, "etime", etime)
+			break
+		}
+		s.logger.Debug("fetched commits from github", "page", searchPage, "items", len(searched.Items), "total_count", searched.TotalCount, "incomplete_results", searched.IncompleteResults)
+
+		page := source.Page{Commits: make([]source.Commit, 0, len(searched.Items))}
+		for _, item := range searched.Items {
+			page.Commits = append(page.Commits, toCommit(item))
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if searchPage*s.perPage >= searched.TotalCount {
+			break
+		}
+		searchPage++
+	}
+
+	return nil
+}
+
+func toCommit(item githubapi.SearchResultItem) source.Commit {
+	c := source.Commit{
+		SHA:     item.SHA,
+		URL:     item.HTMLURL,
+		Message: item.Commit.Message,
+		Author: model.Author{
+			Username:  item.AuthorMeta.Login,
+			AvatarURL: item.AuthorMeta.AvatarURL,
+		},
+		AuthorEmail:    item.Commit.Author.Email,
+		CommitterEmail: item.Commit.Committer.Email,
+		Parents:        len(item.Parents),
+		Time:           item.Commit.Author.Date,
+	}
+	if item.Repository.FullName != "" {
+		c.Repository = &model.Repository{
+			FullName:    item.Repository.FullName,
+			Owner:       item.Repository.Owner.Login,
+			URL:         item.Repository.HTMLURL,
+			Description: item.Repository.Description,
+			Fork:        item.Repository.Fork,
+			Private:     item.Repository.Private,
+		}
+	}
+	return c
+}
+
+// Enrich fills in repository and commit details for commits, using the configured enrichment mode.
+// Enrichment is best effort; on failure commits are left without details.
+func (s *Source) Enrich(ctx context.Context, commits []model.Commit) error {
+	switch s.enrich {
+	case EnrichREST:
+		for i := range commits {
+			s.enrichREST(ctx, &commits[i])
+		}
+	case EnrichGraphQL:
+		byRepo := make(map[string][]*model.Commit)
+		for i := range commits {
+			if commits[i].Repository != nil {
+				byRepo[commits[i].Repository.FullName] = append(byRepo[commits[i].Repository.FullName], &commits[i])
+			}
+		}
+		for repoFullName, repoCommits := range byRepo {
+			cost, err := s.enrichGraphQL(ctx, repoFullName, repoCommits)
+			s.graphqlCost.Add(int64(cost))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GraphQLCost returns GraphQL rate limit points used for enrichment since the source was created.
+func (s *Source) GraphQLCost() int64 {
+	return s.graphqlCost.Load()
+}
+
+// enrichREST fills in details with REST API, which takes a request per commit and one per repository.
+func (s *Source) enrichREST(ctx context.Context, commit *model.Commit) {
+	if commit.Repository == nil {
+		return
+	}
+	repoFullName := commit.Repository.FullName
+	owner, repo, ok := strings.Cut(repoFullName, "/")
+	if !ok {
+		s.logger.Warn("unknown repository for commit, skipping enrichment", "commit_sha", commit.SHA, "repository", repoFullName)
+		return
+	}
+
+	detail, err := s.cli.GetCommit(ctx, owner, repo, commit.SHA)
+	if err != nil {
+		s.logger.Warn("failed to get commit details", "err", err, "commit_sha", commit.SHA, "repository", repoFullName)
+		return
+	}
+	files := make([]model.CommitFile, 0, len(detail.Files))
+	for _, f := range detail.Files {
+		files = append(files, model.CommitFile{
+			Filename:  f.Filename,
+			Status:    f.Status,
+			Additions: f.Additions,
+			Deletions: f.Deletions,
+		})
+	}
+	commit.Details = &model.CommitDetails{
+		Committer: model.Author{
+			Username:  detail.CommitterMeta.Login,
+			AvatarURL: detail.CommitterMeta.AvatarURL,
+		},
+		Additions: detail.Stats.Additions,
+		Deletions: detail.Stats.Deletions,
+		Files:     files,
+		Verification: model.Verification{
+			Verified: detail.Commit.Verification.Verified,
+			Reason:   detail.Commit.Verification.Reason,
+		},
+	}
+
+	// repository language is looked up once per source
+	language, ok := s.repoLanguages[repoFullName]
+	if !ok {
+		r, err := s.cli.GetRepository(ctx, owner, repo)
+		if err != nil {
+			s.logger.Warn("failed to get repository", "err", err, "repository", repoFullName)
+		}
+		language = r.Language
+		s.repoLanguages[repoFullName] = language
+	}
+	commit.Repository.Language = language
+}
+
+// enrichGraphQL fills in details of commits in the same repository with a GraphQL query per 50 commits.
+// It returns rate limit points spent. Only rate limit errors are returned, since further queries would fail as well.
+func (s *Source) enrichGraphQL(ctx context.Context, repoFullName string, commits []*model.Commit) (int, error) {
+	owner, repo, ok := strings.Cut(repoFullName, "/")
+	if !ok {
+		s.logger.Warn("unknown repository for commits, skipping enrichment", "repository", repoFullName)
+		return 0, nil
+	}
+
+	var cost int
+
+	for chunk := range slices.Chunk(commits, githubapi.MaxCommitsPerLookup) {
+		shas := make([]string, 0, len(chunk))
+		for _, c := range chunk {
+			shas = append(shas, c.SHA)
+		}
+		lookup, err := s.cli.LookupCommits(ctx, owner, repo, shas)
+		if err != nil {
+			if errors.Is(err, githubapi.ErrRateLimited) {
+				s.logger.Error("github graphql rate limit exceeded", "err", err)
+				return cost, err
+			}
+			s.logger.Warn("failed to look up commits", "err", err, "repository", repoFullName)
+			continue
+		}
+		cost += lookup.Cost
+		s.logger.Debug("looked up commits with graphql", "repository", repoFullName, "commits", len(shas), "cost", lookup.Cost, "remaining", lookup.Remaining)
+
+		for _, c := range chunk {
+			if lookup.Repository.PrimaryLanguage != nil {
+				c.Repository.Language = lookup.Repository.PrimaryLanguage.Name
+			}
+			c.Repository.Stars = lookup.Repository.StargazerCount
+
+			gc, ok := lookup.Commits[c.SHA]
+			if !ok {
+				continue
+			}
+			details := &model.CommitDetails{
+				Additions: gc.Additions,
+				Deletions: gc.Deletions,
+			}
+			if gc.Committer.User != nil {
+				details.Committer = model.Author{
+					Username:  gc.Committer.User.Login,
+					AvatarURL: gc.Committer.User.AvatarURL,
+				}
+			}
+			if gc.Signature != nil {
+				details.Verification = model.Verification{
+					Verified: gc.Signature.IsValid,
+					Reason:   strings.ToLower(gc.Signature.State),
+				}
+			} else {
+				details.Verification.Reason = "unsigned"
+			}
+			if len(gc.AssociatedPullRequests.Nodes) > 0 {
+				pr := gc.AssociatedPullRequests.Nodes[0]
+				details.PullRequest = &model.PullRequest{
+					Number: pr.Number,
+					Title:  pr.Title,
+					URL:    pr.URL,
+				}
+			}
+			c.Details = details
+		}
+	}
+
+	return cost, nil
+}
@@ -0,0 +1,151 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	pkglog "sb-scanner/pkg/logger"
+)
+
+// Client is a minimal GitLab REST API (v4) client.
+type Client struct {
+	logger  *slog.Logger
+	httpcli *http.Client
+
+	baseURL string // e.g. "https://gitlab.example.com/api/v4"
+	token   string // personal, project or group access token
+}
+
+// Creates GitLab API client for the instance at baseURL (e.g. "https://gitlab.example.com").
+func NewClient(baseURL, token string, httpcli *http.Client) *Client {
+	return &Client{
+		logger:  pkglog.GetLogger().With("pkg", "gitlab"),
+		httpcli: httpcli,
+		baseURL: strings.TrimSuffix(baseURL, "/") + "/api/v4",
+		token:   token,
+	}
+}
+
+// APIError is returned for non-2xx responses from the GitLab API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gitlab api error: %d %s", e.StatusCode, e.Message)
+}
+
+// SearchCommits searches commits matching the search term, in a project if projectID is given or instance-wide otherwise.
+// Instance-wide commit search requires advanced search to be enabled on the instance. Results are requested newest
+// first, since advanced search orders them by relevance otherwise.
+// Returns the next page number, or 0 if there are no more pages.
+func (c *Client) SearchCommits(ctx context.Context, projectID, search string, page, perPage int) ([]Commit, int, error) {
+	path := "/search"
+	if projectID != "" {
+		path = fmt.Sprintf("/projects/%s/search", url.PathEscape(projectID))
+	}
+	q := url.Values{}
+	q.Add("scope", "commits")
+	q.Add("search", search)
+	q.Add("order_by", "created_at")
+	q.Add("sort", "desc")
+	q.Add("page", strconv.Itoa(page))
+	q.Add("per_page", strconv.Itoa(perPage))
+
+	resp, respBytes, err := c.get(ctx, path, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	var result []Commit
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	nextPage, _ := strconv.Atoi(resp.Header.Get("X-Next-Page"))
+
+	return result, nextPage, nil
+}
+
+// GetProject gets a project by numeric ID or URL-encoded path.
+func (c *Client) GetProject(ctx context.Context, id string) (Project, error) {
+	_, respBytes, err := c.get(ctx, "/projects/"+url.PathEscape(id), nil)
+	if err != nil {
+		return Project{}, err
+	}
+	var result Project
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return Project{}, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result, nil
+}
+
+// SearchUsers searches users by name, username or, for users who made it public, email.
+func (c *Client) SearchUsers(ctx context.Context, search string) ([]User, error) {
+	q := url.Values{}
+	q.Add("search", search)
+
+	_, respBytes, err := c.get(ctx, "/users", q)
+	if err != nil {
+		return nil, err
+	}
+	var result []User
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, q url.Values) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.URL.RawQuery = q.Encode()
+	req.Header.Add("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Add("PRIVATE-TOKEN", c.token)
+	}
+	c.logger.Debug("gitlab request", "path", path, "query", req.URL.RawQuery)
+
+	resp, err := c.httpcli.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, newAPIError(resp.StatusCode, respBytes)
+	}
+
+	return resp, respBytes, nil
+}
+
+func newAPIError(status int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: status, Message: strings.TrimSpace(string(body))}
+	var errResp errorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return apiErr
+	}
+	var msg string
+	switch {
+	case json.Unmarshal(errResp.Message, &msg) == nil:
+		apiErr.Message = msg
+	case len(errResp.Message) > 0:
+		apiErr.Message = string(errResp.Message)
+	case errResp.Error != "":
+		apiErr.Message = errResp.Error
+	}
+	return apiErr
+}
@@ -0,0 +1,47 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Commit struct {
+	ID             string    `json:"id"`
+	Title          string    `json:"title"`
+	Message        string    `json:"message"`
+	AuthorName     string    `json:"author_name"`
+	AuthorEmail    string    `json:"author_email"`
+	AuthoredDate   time.Time `json:"authored_date"`
+	CommitterName  string    `json:"committer_name"`
+	CommitterEmail string    `json:"committer_email"`
+	CommittedDate  time.Time `json:"committed_date"`
+	ParentIDs      []string  `json:"parent_ids"`
+	WebURL         string    `json:"web_url"`
+	ProjectID      int       `json:"project_id"`
+}
+
+type Namespace struct {
+	FullPath string `json:"full_path"`
+}
+
+type Project struct {
+	ID                int             `json:"id"`
+	PathWithNamespace string          `json:"path_with_namespace"`
+	Description       string          `json:"description"`
+	WebURL            string          `json:"web_url"`
+	Visibility        string          `json:"visibility"` // "public", "internal", "private"
+	Namespace         Namespace       `json:"namespace"`
+	ForkedFromProject json.RawMessage `json:"forked_from_project"` // only present on forks
+}
+
+type User struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type errorResponse struct {
+	Message json.RawMessage `json:"message"` // string or object of field errors
+	Error   string          `json:"error"`
+}
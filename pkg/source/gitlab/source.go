@@ -0,0 +1,181 @@
+package gitlab
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"sb-scanner/model"
+	pkglog "sb-scanner/pkg/logger"
+	"sb-scanner/pkg/source"
+)
+
+// Source searches commits with GitLab commits search API.
+type Source struct {
+	logger *slog.Logger
+	cli    *Client
+
+	projects     []string // project IDs or paths to search in; instance-wide if empty
+	perPage      int
+	maxPages     int
+	allowPrivate bool // whether commits of private and internal projects are kept, and so shown on the public feed
+
+	projectCache map[int]*model.Repository
+	authorCache  map[string]model.Author // by author email
+}
+
+func NewSource(cli *Client, projects []string, perPage, maxPages int, allowPrivate bool) *Source {
+	return &Source{
+		logger:       pkglog.GetLogger().With("pkg", "source/gitlab"),
+		cli:          cli,
+		projects:     projects,
+		perPage:      perPage,
+		maxPages:     maxPages,
+		allowPrivate: allowPrivate,
+		projectCache: make(map[int]*model.Repository),
+		authorCache:  make(map[string]model.Author),
+	}
+}
+
+func (s *Source) Name() string {
+	return "gitlab"
+}
+
+func (s *Source) Search(ctx context.Context, q source.Query, fn func(source.Page) error) error {
+	var projects []string
+	for _, project := range s.projects {
+		if !s.allowPrivate && !s.public(ctx, project) {
+			s.logger.Warn("skipping project that is not public; set sources.gitlab.allow_private to search it", "project", project)
+			continue
+		}
+		projects = append(projects, project)
+	}
+	if len(s.projects) == 0 {
+		projects = []string{""}
+	}
+
+	// GitLab search takes a single term and has no date filter; search keywords one by one and filter here.
+	// Results are requested newest first by commit date, so paging stops at the first page ending before the window,
+	// unless a page turns out not to be in order.
+	for _, keyword := range q.Group.Keywords {
+		for _, project := range projects {
+			var oldest time.Time
+			ordered := true
+			searchPage := 1
+			for searchPage <= s.maxPages {
+				items, nextPage, err := s.cli.SearchCommits(ctx, project, keyword, searchPage, s.perPage)
+				if err != nil {
+					s.logger.Error("failed to search commits", "err", err, "project", project, "keyword", keyword)
+					return err
+				}
+				s.logger.Debug("fetched commits from gitlab", "project", project, "page", searchPage, "items", len(items))
+
+				var page source.Page
+				for _, item := range items {
+					if ordered && !oldest.IsZero() && item.CommittedDate.After(oldest) {
+						ordered = false
+						s.logger.Debug("search results are not ordered by date, searching all pages", "project", project, "keyword", keyword)
+					}
+					oldest = item.CommittedDate
+					if item.AuthoredDate.Before(q.Since) || item.AuthoredDate.After(q.Until) ||
+						source.MatchesAny(item.Message, q.Group.Exclude) {
+						continue
+					}
+					repo := s.repository(ctx, item.ProjectID)
+					if !s.allowPrivate && (repo == nil || repo.Private) {
+						// instance-wide results include projects the token can read, whose visibility is checked per item
+						continue
+					}
+					page.Commits = append(page.Commits, s.toCommit(ctx, item, repo))
+				}
+				if len(page.Commits) > 0 {
+					if err := fn(page); err != nil {
+						return err
+					}
+				}
+				// commits are authored before they are committed, so later pages are authored before the window too
+				if nextPage == 0 || (ordered && len(items) > 0 && oldest.Before(q.Since)) {
+					break
+				}
+				if nextPage > s.maxPages {
+					s.logger.Warn("reached max_pages with results still inside the window; older commits are not searched",
+						"project", project, "keyword", keyword, "max_pages", s.maxPages, "since", q.Since)
+				}
+				searchPage = nextPage
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Source) toCommit(ctx context.Context, item Commit, repo *model.Repository) source.Commit {
+	c := source.Commit{
+		SHA:            item.ID,
+		URL:            item.WebURL,
+		Message:        item.Message,
+		Author:         s.author(ctx, item.AuthorEmail),
+		AuthorEmail:    item.AuthorEmail,
+		CommitterEmail: item.CommitterEmail,
+		Parents:        len(item.ParentIDs),
+		Time:           item.AuthoredDate,
+		Repository:     repo,
+	}
+	if c.URL == "" && c.Repository != nil {
+		c.URL = c.Repository.URL + "/-/commit/" + item.ID
+	}
+	return c
+}
+
+// author looks up the user of an author email once per email. GitLab commits only carry the author's display name,
+// so the username stays empty, and login filters don't apply, unless a single user has made the email public.
+func (s *Source) author(ctx context.Context, email string) model.Author {
+	if a, ok := s.authorCache[email]; ok || email == "" {
+		return a
+	}
+	var a model.Author
+	users, err := s.cli.SearchUsers(ctx, email)
+	switch {
+	case err != nil:
+		s.logger.Warn("failed to search users", "err", err, "author_email", email)
+	case len(users) == 1:
+		a = model.Author{Username: users[0].Username, AvatarURL: users[0].AvatarURL}
+	}
+	s.authorCache[email] = a
+	return a
+}
+
+// public reports whether a configured project is public. Projects that can't be looked up are taken as not public.
+func (s *Source) public(ctx context.Context, project string) bool {
+	p, err := s.cli.GetProject(ctx, project)
+	if err != nil {
+		s.logger.Warn("failed to get project", "err", err, "project", project)
+		return false
+	}
+	return p.Visibility == "public"
+}
+
+// repository looks up project metadata once per project; returns nil on failure, in which case commits are only
+// kept if private projects are allowed.
+func (s *Source) repository(ctx context.Context, projectID int) *model.Repository {
+	if r, ok := s.projectCache[projectID]; ok {
+		return r
+	}
+	p, err := s.cli.GetProject(ctx, strconv.Itoa(projectID))
+	if err != nil {
+		s.logger.Warn("failed to get project", "err", err, "project_id", projectID)
+		s.projectCache[projectID] = nil
+		return nil
+	}
+	r := &model.Repository{
+		FullName:    p.PathWithNamespace,
+		Owner:       p.Namespace.FullPath,
+		URL:         p.WebURL,
+		Description: p.Description,
+		Fork:        len(p.ForkedFromProject) > 0 && string(p.ForkedFromProject) != "null",
+		Private:     p.Visibility != "public",
+	}
+	s.projectCache[projectID] = r
+	return r
+}
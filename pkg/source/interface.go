@@ -0,0 +1,60 @@
+package source
+
+import (
+	"context"
+	"time"
+
+	"sb-scanner/model"
+)
+
+// Commit is a commit found by a source, normalized across code hosting services.
+type Commit struct {
+	SHA            string
+	URL            string
+	Message        string
+	Author         model.Author
+	AuthorEmail    string
+	CommitterEmail string
+	Parents        int // number of parent commits; more than 1 for merge commits
+	Time           time.Time
+	Repository     *model.Repository
+}
+
+// KeywordGroup is a named set of keywords searched together.
+type KeywordGroup struct {
+	Name       string   `mapstructure:"name"`
+	Keywords   []string `mapstructure:"keywords"`   // commits whose message contains any of the keywords are matched
+	Exclude    []string `mapstructure:"exclude"`    // commits whose message contains any of these are not matched
+	Qualifiers []string `mapstructure:"qualifiers"` // GitHub search qualifiers, e.g. "org:name", "is:public"; ignored by other sources
+}
+
+// Matches reports whether the message matches the group's keywords, for sources without server-side search.
+func (g KeywordGroup) Matches(message string) bool {
+	return MatchesAny(message, g.Keywords) && !MatchesAny(message, g.Exclude)
+}
+
+// Query selects commits to search for.
+type Query struct {
+	Group KeywordGroup
+	Since time.Time // commit time lower bound, inclusive
+	Until time.Time // commit time upper bound, inclusive
+}
+
+// Page is a batch of commits yielded by a source.
+type Page struct {
+	Commits []Commit
+}
+
+type CommitSource interface {
+	// Name identifies the source; stored on every commit found by it.
+	Name() string
+	// Search calls fn for every page of commits matching the query, stopping at the first error returned by fn.
+	Search(ctx context.Context, q Query, fn func(Page) error) error
+}
+
+// Enricher is implemented by sources that can fill in details that search results don't have.
+type Enricher interface {
+	// Enrich updates the commits in place; commits found by the source are passed in batches.
+	// It may be called while Search is running, but not concurrently with itself.
+	Enrich(ctx context.Context, commits []model.Commit) error
+}
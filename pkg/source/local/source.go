@@ -0,0 +1,157 @@
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sb-scanner/model"
+	pkglog "sb-scanner/pkg/logger"
+	"sb-scanner/pkg/source"
+)
+
+const (
+	// git log output is split into records and fields with ASCII separators, which don't appear in commit messages
+	recordSeparator = "\x1e"
+	fieldSeparator  = "\x1f"
+	// hash, author name, author email, committer email, author date, parent hashes, raw body
+	logFormat = "%x1e%H%x1f%an%x1f%ae%x1f%ce%x1f%aI%x1f%P%x1f%B"
+
+	pageSize = 100
+)
+
+// Source scans history of a local git repository by parsing `git log` output.
+// Keywords are matched locally.
+type Source struct {
+	logger *slog.Logger
+
+	path string // path to the repository working tree or bare repository
+	name string // repository name stored on commits
+}
+
+// Creates local repository source. If name is empty, base name of the path is used.
+func NewSource(path, name string) *Source {
+	if name == "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = path
+		}
+		name = filepath.Base(abs)
+	}
+	return &Source{
+		logger: pkglog.GetLogger().With("pkg", "source/local"),
+		path:   path,
+		name:   name,
+	}
+}
+
+func (s *Source) Name() string {
+	return "local"
+}
+
+// Search walks all commits reachable from HEAD. Zero Since/Until in the query means no bound.
+// Commits are filtered by author date here like with other sources, since git log --since/--until filter by committer
+// date.
+func (s *Source) Search(ctx context.Context, q source.Query, fn func(source.Page) error) error {
+	args := []string{"-C", s.path, "log", "--format=" + logFormat}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open git log output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run git log: %w", err)
+	}
+
+	repository := &model.Repository{FullName: s.name}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	scanner.Split(splitRecords)
+
+	var scanned int
+	var page source.Page
+	for scanner.Scan() {
+		record := strings.TrimSpace(scanner.Text())
+		if record == "" {
+			continue
+		}
+		c, err := parseRecord(record)
+		if err != nil {
+			s.logger.Warn("skipping unparsable git log record", "err", err)
+			continue
+		}
+		scanned++
+		if (!q.Since.IsZero() && c.Time.Before(q.Since)) || (!q.Until.IsZero() && c.Time.After(q.Until)) {
+			continue
+		}
+		if !q.Group.Matches(c.Message) {
+			continue
+		}
+		c.Repository = repository
+		page.Commits = append(page.Commits, c)
+		if len(page.Commits) >= pageSize {
+			if err := fn(page); err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return err
+			}
+			page = source.Page{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("failed to read git log output: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git log failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if len(page.Commits) > 0 {
+		if err := fn(page); err != nil {
+			return err
+		}
+	}
+	s.logger.Debug("scanned local repository", "path", s.path, "commits", scanned)
+
+	return nil
+}
+
+func splitRecords(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.Index(data, []byte(recordSeparator)); i >= 0 {
+		return i + len(recordSeparator), data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func parseRecord(record string) (source.Commit, error) {
+	fields := strings.SplitN(record, fieldSeparator, 7)
+	if len(fields) != 7 {
+		return source.Commit{}, fmt.Errorf("expected 7 fields, got %d", len(fields))
+	}
+	t, err := time.Parse(time.RFC3339, fields[4])
+	if err != nil {
+		return source.Commit{}, fmt.Errorf("failed to parse author date: %w", err)
+	}
+	return source.Commit{
+		SHA:     fields[0],
+		Message: strings.TrimSpace(fields[6]),
+		Author: model.Author{
+			Username: fields[1],
+		},
+		AuthorEmail:    fields[2],
+		CommitterEmail: fields[3],
+		Parents:        len(strings.Fields(fields[5])),
+		Time:           t,
+	}, nil
+}
@@ -0,0 +1,15 @@
+package source
+
+import "strings"
+
+// MatchesAny reports whether the message contains any of the keywords, ignoring case.
+// Used by sources without server-side commit search.
+func MatchesAny(message string, keywords []string) bool {
+	lower := strings.ToLower(message)
+	for _, k := range keywords {
+		if k != "" && strings.Contains(lower, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
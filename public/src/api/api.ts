@@ -27,12 +27,15 @@ export interface SearchCommitResponse {
 }
 
 export interface Commit {
+  source?: string;
   sha: string;
   url: string;
   message: string;
   author: Author;
   time: string;
   sentiment: Sentiment;
+  repository?: Repository;
+  repositories?: string[];
 }
 
 export interface Author {
@@ -44,3 +47,13 @@ export interface Sentiment {
   score: number;
   model: string;
 }
+
+export interface Repository {
+  full_name: string;
+  owner: string;
+  url: string;
+  description?: string;
+  fork: boolean;
+  private: boolean;
+  language?: string;
+}
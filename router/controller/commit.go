@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-chi/render"
 
+	"sb-scanner/model"
 	pkglog "sb-scanner/pkg/logger"
 	"sb-scanner/pkg/repository"
 )
@@ -40,6 +41,10 @@ func (c *Controller) GetCommits(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Query().Get("bookmark") != "" {
 		bookmark = new(string)
 		*bookmark = r.URL.Query().Get("bookmark")
+		if _, _, err := model.ParseCommitID(*bookmark); err != nil {
+			render.Render(w, r, MakeBadRequestError("query parameter `bookmark` is invalid"))
+			return
+		}
 	}
 	var limit int64 = 30
 	if r.URL.Query().Get("limit") != "" {
@@ -24,3 +24,11 @@ func MakeInternalServerError() *ErrResponse {
 func MakeBadRequestError(msg string) *ErrResponse {
 	return &ErrResponse{http.StatusBadRequest, "0001", msg}
 }
+
+func MakeUnauthorizedError(msg string) *ErrResponse {
+	return &ErrResponse{http.StatusUnauthorized, "0002", msg}
+}
+
+func MakeServiceUnavailableError(msg string) *ErrResponse {
+	return &ErrResponse{http.StatusServiceUnavailable, "0003", msg}
+}
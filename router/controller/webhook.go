@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"sb-scanner/model"
+	"sb-scanner/pkg/github"
+	pkglog "sb-scanner/pkg/logger"
+	"sb-scanner/pkg/repository"
+	"sb-scanner/pkg/sentiment"
+	"sb-scanner/pkg/source"
+)
+
+const (
+	maxWebhookPayloadSize = 25 << 20 // GitHub caps webhook payloads at 25MB
+	webhookQueueSize      = 100      // push events waiting for a worker; more are rejected
+)
+
+type WebhookController struct {
+	logger    *slog.Logger
+	repo      *repository.Repository
+	evaluator sentiment.Evaluator
+
+	secret          string
+	keywordGroups   []source.KeywordGroup
+	maxCommitLength int
+	allowPrivate    bool // whether commits of private repositories are stored, and so shown on the public feed
+
+	mu      sync.RWMutex
+	closed  bool
+	jobs    chan pushJob
+	workers sync.WaitGroup
+}
+
+// pushJob is a push event with its commits to be evaluated.
+type pushJob struct {
+	event   github.PushEvent
+	commits []github.PushCommit
+}
+
+// NewWebhookController creates the controller and starts workers processing push events, at most concurrency at a
+// time. Workers are stopped with Close.
+func NewWebhookController(repo *repository.Repository, evaluator sentiment.Evaluator, secret string, keywordGroups []source.KeywordGroup, maxCommitLength int, allowPrivate bool, concurrency int) *WebhookController {
+	c := &WebhookController{
+		logger:          pkglog.GetLogger().With("pkg", "controller"),
+		repo:            repo,
+		evaluator:       evaluator,
+		secret:          secret,
+		keywordGroups:   keywordGroups,
+		maxCommitLength: maxCommitLength,
+		allowPrivate:    allowPrivate,
+		jobs:            make(chan pushJob, webhookQueueSize),
+	}
+	for range concurrency {
+		c.workers.Add(1)
+		go func() {
+			defer c.workers.Done()
+			for job := range c.jobs {
+				c.processPush(context.Background(), job.event, job.commits)
+			}
+		}()
+	}
+	return c
+}
+
+// Close stops accepting push events and waits until the queued ones are processed or ctx is done.
+// It must be called after the server has stopped serving requests.
+func (c *WebhookController) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.jobs)
+	}
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		c.logger.Warn("stopped waiting for queued push events", "queued", len(c.jobs))
+		return ctx.Err()
+	}
+}
+
+// enqueue queues the job for a worker. It reports false if the queue is full or closed.
+func (c *WebhookController) enqueue(job pushJob) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return false
+	}
+	select {
+	case c.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// GitHubWebhook godoc
+// @Summary      Receives GitHub webhook events
+// @Description  Commits from push events are evaluated and saved in the background.
+// @Tags         webhook
+// @Accept       json
+// @Produce      json
+// @Param        X-GitHub-Event       header string true "event type"
+// @Param        X-Hub-Signature-256  header string true "HMAC-SHA256 signature of payload"
+// @Success      202
+// @Failure      400  {object}  rerr.ErrResponse
+// @Failure      401  {object}  rerr.ErrResponse
+// @Failure      503  {object}  rerr.ErrResponse
+// @Router       /api/v1/webhooks/github [post]
+func (c *WebhookController) GitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	logger := c.logger.With("func", "GitHubWebhook")
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayloadSize))
+	if err != nil {
+		render.Render(w, r, MakeBadRequestError("failed to read request body"))
+		return
+	}
+	if err := github.VerifyWebhookSignature(payload, r.Header.Get("X-Hub-Signature-256"), c.secret); err != nil {
+		logger.Warn("webhook signature verification failed", "delivery", r.Header.Get("X-GitHub-Delivery"))
+		render.Render(w, r, MakeUnauthorizedError("invalid signature"))
+		return
+	}
+
+	switch event := r.Header.Get("X-GitHub-Event"); event {
+	case "push":
+	case "ping":
+		w.WriteHeader(http.StatusOK)
+		return
+	default:
+		logger.Debug("ignoring webhook event", "event", event)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	var event github.PushEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		render.Render(w, r, MakeBadRequestError("invalid push event payload"))
+		return
+	}
+	if event.Repository.Private && !c.allowPrivate {
+		logger.Debug("ignoring push event of private repository", "repository", event.Repository.FullName)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	var matched []github.PushCommit
+	for _, pc := range event.Commits {
+		if pc.Distinct && c.matches(pc.Message) && len(pc.Message) <= c.maxCommitLength {
+			matched = append(matched, pc)
+		}
+	}
+	logger.Info("received push event", "repository", event.Repository.FullName, "commits", len(event.Commits), "commits_matched", len(matched))
+
+	// sentiment evaluation can take longer than GitHub waits for webhook responses
+	if len(matched) > 0 && !c.enqueue(pushJob{event: event, commits: matched}) {
+		logger.Warn("push event queue is full, rejecting event", "repository", event.Repository.FullName, "delivery", r.Header.Get("X-GitHub-Delivery"))
+		render.Render(w, r, MakeServiceUnavailableError("too many push events being processed"))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// matches reports whether the message matches any of the keyword groups.
+func (c *WebhookController) matches(message string) bool {
+	for _, g := range c.keywordGroups {
+		if g.Matches(message) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *WebhookController) processPush(ctx context.Context, event github.PushEvent, pushCommits []github.PushCommit) {
+	logger := c.logger.With("func", "processPush", "repository", event.Repository.FullName)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	repository := &model.Repository{
+		FullName:    event.Repository.FullName,
+		Owner:       event.Repository.Owner.Login,
+		URL:         event.Repository.HTMLURL,
+		Description: event.Repository.Description,
+		Fork:        event.Repository.Fork,
+		Private:     event.Repository.Private,
+	}
+	var commits []model.Commit
+	for _, pc := range pushCommits {
+		sentiment, err := c.evaluator.Evaluate(ctx, pc.Message)
+		if err != nil {
+			logger.Error("failed to evaluate sentiment", "err", err, "commit_sha", pc.ID)
+			return
+		}
+		if !sentiment.ContainsProfanity {
+			continue
+		}
+
+		author := model.Author{Username: pc.Author.Username}
+		if author.Username == "" {
+			author.Username = pc.Author.Name
+		}
+		if pc.Author.Username != "" && pc.Author.Username == event.Sender.Login {
+			author.AvatarURL = event.Sender.AvatarURL
+		}
+		commits = append(commits, model.Commit{
+			ID:      model.NewCommitID(pc.Timestamp, pc.ID),
+			Source:  "github",
+			SHA:     pc.ID,
+			URL:     pc.URL,
+			Message: pc.Message,
+			Author:  author,
+			Time:    pc.Timestamp,
+			Sentiment: model.Sentiment{
+				Score: sentiment.Score,
+				Model: sentiment.Model,
+			},
+			Repository:   repository,
+			Repositories: []string{repository.FullName},
+			Fingerprint:  model.NewCommitFingerprint(pc.Author.Email, pc.Timestamp, pc.Message),
+		})
+	}
+	if len(commits) == 0 {
+		return
+	}
+	if err := c.repo.PutCommits(ctx, commits); err != nil {
+		logger.Error("failed to put commits to db", "err", err)
+		return
+	}
+	logger.Info("inserted commits from push event", "commits_inserted", len(commits))
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	"net/http"
 	"os"
 
@@ -10,15 +11,20 @@ import (
 	"github.com/go-chi/render"
 	"github.com/spf13/viper"
 
+	"sb-scanner/pkg/config"
 	pkglog "sb-scanner/pkg/logger"
 	"sb-scanner/pkg/repository"
+	"sb-scanner/pkg/sentiment/ollama"
 	"sb-scanner/router/controller"
 )
 
 // @title                       SB Scanner API
 // @version                     v1
 // @BasePath                    /
-func New(v *viper.Viper, debug bool) http.Handler {
+//
+// New returns the handler and a func waiting for background work of the handler to finish, to be called after the
+// server has shut down.
+func New(v *viper.Viper, debug bool) (http.Handler, func(context.Context) error) {
 	logger := pkglog.GetLogger().With("pkg", "router")
 	r := chi.NewRouter()
 
@@ -39,12 +45,50 @@ func New(v *viper.Viper, debug bool) http.Handler {
 	r.Use(render.SetContentType(render.ContentTypeJSON))
 
 	ctrl := controller.NewController(repo)
+	var webhookCtrl *controller.WebhookController
+	if v.GetBool("github.webhook.enabled") {
+		secret := v.GetString("github.webhook.secret")
+		if secret == "" {
+			logger.Error("github.webhook.secret is not set")
+			os.Exit(1)
+		}
+		ollamaModel := v.GetString("ollama.model")
+		if ollamaModel == "" {
+			logger.Error("ollama.model is not set")
+			os.Exit(1)
+		}
+		ollamaURL := v.GetString("ollama.url")
+		if ollamaURL == "" {
+			logger.Warn("ollama.url is not set; using default(http://localhost:11434)")
+			ollamaURL = "http://localhost:11434"
+		}
+		maxCommitLength := v.GetInt("github.search.max_commit_length")
+		if maxCommitLength <= 0 {
+			maxCommitLength = 500
+		}
+		keywordGroups, err := config.ReadKeywordGroups(v)
+		if err != nil {
+			logger.Error("failed to read keyword groups", "err", err)
+			os.Exit(1)
+		}
+		concurrency := v.GetInt("github.webhook.concurrency")
+		if concurrency <= 0 {
+			logger.Warn("invalid github.webhook.concurrency, using default (2)")
+			concurrency = 2
+		}
+		evaluator := ollama.NewOllamaEvaluator(ollamaModel, ollamaURL)
+		webhookCtrl = controller.NewWebhookController(repo, evaluator, secret, keywordGroups, maxCommitLength,
+			v.GetBool("github.webhook.allow_private"), concurrency)
+	}
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("OK"))
 	})
 	r.Route("/api", func(r chi.Router) {
 		r.Route("/v1", func(r chi.Router) {
 			r.Get("/commit", ctrl.GetCommits)
+			if webhookCtrl != nil {
+				r.Post("/webhooks/github", webhookCtrl.GitHubWebhook)
+			}
 		})
 		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -59,5 +103,11 @@ func New(v *viper.Viper, debug bool) http.Handler {
 		http.ServeFile(w, r, "./public/dist/index.html")
 	})
 
-	return r
+	drain := func(ctx context.Context) error {
+		if webhookCtrl == nil {
+			return nil
+		}
+		return webhookCtrl.Close(ctx)
+	}
+	return r, drain
 }